@@ -1,122 +1,1207 @@
 package cache
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// ErrClosed is returned by cache operations attempted after Close.
+var ErrClosed = errors.New("cache is closed")
+
+// ErrFrozen is returned by cache operations attempted while the cache is
+// frozen.
+var ErrFrozen = errors.New("cache is frozen")
+
+// ErrFull is returned by TrySet, SetChecked and Add when storing a new key
+// would exceed the capacity and the overflow policy rejects the write.
+var ErrFull = errors.New("cache is full")
+
+// Now returns the current time. All expiry computations go through it, so
+// tests can replace it to control the passage of time. It is read without
+// synchronization: replace it only while no cache is in use, e.g. before
+// starting parallel tests or cleanup goroutines, and restore it afterwards.
+var Now = time.Now
+
+// cacheCount numbers the caches created without an explicit name.
+var cacheCount atomic.Uint64
+
+// cacheID numbers every cache in creation order, giving Move a global lock
+// order.
+var cacheID atomic.Uint64
+
 type Cache[K comparable, V any] struct {
-	items map[K]item[V]
-	mu    sync.RWMutex
+	id     uint64
+	name   string
+	items  map[K]item[V]
+	mu     sync.RWMutex
+	closed bool
+	frozen bool
+	done   chan struct{}
+
+	closedPolicy    ClosedPolicy
+	frozenPolicy    FrozenPolicy
+	replaceExpired  ReplaceExpiredPolicy
+	replaceKeepsTTL bool
+	manualCleanup   bool
+
+	adaptive   bool
+	minCleanup time.Duration
+	maxCleanup time.Duration
+
+	schedule func(now time.Time) time.Duration
+
+	validate func(key K, value V) error
+
+	refreshThreshold time.Duration
+	refreshLoader    func(keys []K) (map[K]V, error)
+
+	cleanupObserver func(CleanupReport)
+	sweepHandler    func(key K, value V)
+	readObserver    func(key K, hit bool)
+
+	sampleInterval time.Duration
+	sampler        func(size int)
+
+	compareKeys func(a, b K) int
+	lockPoll    time.Duration
+	noExpiry    bool
+	asyncSweep  atomic.Bool
+
+	expiryFunc func(key K, value V, setAt time.Time) bool
+
+	autoCompact float64
+	peak        int
+
+	hotWindow  time.Duration
+	hotMinHits int
+
+	onEmptyState func(empty bool)
+	populated    bool
+	evicting     bool
+
+	sizeOf func(key K, value V) int64
+	bytes  int64
+
+	capacity int
+	overflow OverflowPolicy
+	rng      *rand.Rand
+	backend  Backend[K, V]
+
+	maxRenewals int
+	policy      EvictionPolicy[K]
+
+	equals func(a, b V) bool
+	cloner func(V) V
+
+	events      chan Event[K, V]
+	subscribers map[K]map[*subscription[K, V]]struct{}
+	dropPolicy  DropPolicy
+	counters    counters
+
+	// batchExpiry is set by WithBatchedExpiry, and sweeping while a sweep
+	// collects its expirations into a single event.
+	batchExpiry bool
+	sweeping    bool
+
+	version uint64
+
+	tombstoneGrace time.Duration
+	tombstones     map[K]time.Time
+
+	// dependents maps a key to the keys that depend on it, and
+	// dependencies maps a key to the keys it depends on.
+	dependents   map[K]map[K]struct{}
+	dependencies map[K][]K
+
+	// tagged maps a tag to the keys carrying it, and tags maps a key to its
+	// tags.
+	tagged map[string]map[K]struct{}
+	tags   map[K][]string
+
+	granularity time.Duration
+
+	loader    func(key K) (V, time.Duration, error)
+	loadMu    sync.Mutex
+	calls     map[K]*call[V]
+	loadSlots chan struct{}
+
+	loaderTimeout time.Duration
+
+	staleWhileRevalidate bool
+	staleOnError         bool
+}
+
+// Store is the set of core cache operations implemented by *Cache. Consumers
+// can depend on Store instead of the concrete type and substitute their own
+// implementation in tests.
+type Store[K comparable, V any] interface {
+	Set(key K, data V, ttl time.Duration)
+	Add(key K, data V, ttl time.Duration) error
+	Replace(key K, data V, ttl time.Duration) error
+	Get(key K) (V, bool)
+	Pop(key K) (V, bool)
+	Remove(key K)
+	RemoveExpired()
+	Clear()
+}
+
+var (
+	_ Store[string, any] = (*Cache[string, any])(nil)
+	_ io.Closer          = (*Cache[string, any])(nil)
+)
+
+type item[V any] struct {
+	value   V
+	expiry  time.Time
+	ttl     time.Duration
+	size    int64
+	version uint64
+	hits    uint64
+
+	// created is when the value was stored. Writes only adjusting the
+	// expiry keep it.
+	created time.Time
+
+	// idle is the maximum time the item may go unread, counted from
+	// accessed. Zero disables the idle timeout.
+	idle     time.Duration
+	accessed time.Time
+
+	// stale marks an expired item already served once by
+	// WithStaleWhileRevalidate.
+	stale bool
+
+	// sticky marks an item stored with SetSticky, kept by Clear and never
+	// evicted.
+	sticky bool
+
+	// window and windowHits count the reads since window began, for
+	// WithHotSliding.
+	window     time.Time
+	windowHits int
+
+	// renewals counts the extensions of the expiry by reads, bounded by
+	// WithMaxRenewals.
+	renewals int
+}
+
+// New initializes a new Cache instance and launches a goroutine that
+// periodically removes expired items from the cache based on the specified
+// cleanupInterval, unless WithManualCleanup is given or cleanupInterval is
+// not positive, in which case expired items are only removed lazily or by
+// RunCleanup. Optional behavior can be configured by passing one or more
+// options.
+func New[K comparable, V any](cleanupInterval time.Duration, opts ...Option[K, V]) *Cache[K, V] {
+
+	c := &Cache[K, V]{
+		id:    cacheID.Add(1),
+		items: make(map[K]item[V]),
+		done:  make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.name == "" {
+		c.name = fmt.Sprintf("cache-%d", cacheCount.Add(1))
+	}
+
+	if c.capacity > 0 && c.policy == nil {
+		c.policy = NewRandomEviction[K](0)
+	}
+	if p, ok := c.policy.(*RandomEviction[K]); ok && c.rng != nil && p.rng == nil {
+		p.rng = c.rng
+	}
+
+	if !c.manualCleanup && cleanupInterval > 0 {
+		go c.cleanupLoop(cleanupInterval)
+	}
+
+	if c.sampler != nil && c.sampleInterval > 0 {
+		go c.sampleLoop()
+	}
+
+	return c
+}
+
+// NewLRU returns a cache holding at most maxItems items that never expire,
+// evicting the least recently used item to make room for a new one. The TTL
+// passed to write methods is ignored, and no cleanup goroutine is started:
+// items only leave the cache when evicted or removed explicitly. Further
+// options can be passed as for New.
+func NewLRU[K comparable, V any](maxItems int, opts ...Option[K, V]) *Cache[K, V] {
+
+	opts = append([]Option[K, V]{
+		WithCapacity[K, V](maxItems),
+		WithEvictionPolicy[K, V](NewLRUEviction[K]()),
+		func(c *Cache[K, V]) { c.noExpiry = true },
+	}, opts...)
+
+	return New(0, append(opts, WithManualCleanup[K, V]())...)
+}
+
+// Name returns the name of the cache, as set by WithName or generated by New.
+func (c *Cache[K, V]) Name() string {
+	return c.name
+}
+
+// RunCleanup performs a single cleanup pass, removing every expired item
+// exactly as the background goroutine does. It is mainly meant for caches
+// created with WithManualCleanup.
+func (c *Cache[K, V]) RunCleanup() {
+	c.sweep()
+	c.refreshAhead()
+}
+
+// Close stops the cleanup goroutine, removes all items and closes the
+// events channel, if any, and the channels returned by Subscribe. After Close,
+// mutating methods behave according to the configured ClosedPolicy; by
+// default, methods returning an error report ErrClosed and other mutating
+// methods are no-ops. Calling Close more than once has no effect. Close
+// implements io.Closer and currently always returns nil.
+func (c *Cache[K, V]) Close() error {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+
+	c.closed = true
+	close(c.done)
+	c.clear(false)
+
+	if c.events != nil {
+		close(c.events)
+	}
+
+	for _, subs := range c.subscribers {
+		for sub := range subs {
+			sub.stop()
+		}
+	}
+	clear(c.subscribers)
+
+	return nil
+}
+
+// IsClosed reports whether Close has been called on the cache.
+func (c *Cache[K, V]) IsClosed() bool {
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.closed
+}
+
+// Freeze makes the cache read-only until Unfreeze is called. While frozen,
+// mutating methods behave according to the configured FrozenPolicy: by
+// default, methods returning an error report ErrFrozen and the others are
+// no-ops. Reads proceed normally but leave expired items in place, and the
+// cleanup goroutine skips its passes, so the set of stored items stays
+// stable for the whole frozen window.
+func (c *Cache[K, V]) Freeze() {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.frozen = true
+}
+
+// Unfreeze makes a frozen cache writable again.
+func (c *Cache[K, V]) Unfreeze() {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.frozen = false
+}
+
+// IsFrozen reports whether the cache is frozen.
+func (c *Cache[K, V]) IsFrozen() bool {
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.frozen
+}
+
+// Set inserts an item to the cache, replacing any existing one.
+func (c *Cache[K, V]) Set(key K, data V, ttl time.Duration) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if blocked, _ := c.writeErr(); blocked {
+		return
+	}
+
+	c.set(key, data, ttl)
+}
+
+// TrySet inserts an item like Set, but returns ErrFull instead of dropping
+// the write when the key is new, the cache is at capacity, nothing can be
+// evicted and the overflow policy is OverflowReject.
+func (c *Cache[K, V]) TrySet(key K, data V, ttl time.Duration) error {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if blocked, err := c.writeErr(); blocked {
+		return err
+	}
+
+	if !c.set(key, data, ttl) {
+		return ErrFull
+	}
+
+	return nil
+}
+
+// SetChecked inserts an item like Set, unless the validator set with
+// WithSetValidator rejects it, in which case the validator's error is
+// returned and nothing is stored. Set itself skips the validator. Like
+// TrySet, it returns ErrFull if the overflow policy rejects the write.
+func (c *Cache[K, V]) SetChecked(key K, data V, ttl time.Duration) error {
+
+	if err := c.check(key, data); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if blocked, err := c.writeErr(); blocked {
+		return err
+	}
+
+	if !c.set(key, data, ttl) {
+		return ErrFull
+	}
+
+	return nil
+}
+
+// SetReporting inserts an item like Set and reports whether it replaced a
+// live item. Overwriting an expired item reports false.
+func (c *Cache[K, V]) SetReporting(key K, data V, ttl time.Duration) (replaced bool) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if blocked, _ := c.writeErr(); blocked {
+		return false
+	}
+
+	i, found := c.items[key]
+	c.set(key, data, ttl)
+
+	return found && !c.expired(key, i)
+}
+
+// Swap stores an item under key, replacing any existing one, and returns the
+// previous value along with whether it was live. An expired previous item is
+// overwritten but reported as absent.
+func (c *Cache[K, V]) Swap(key K, data V, ttl time.Duration) (V, bool) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var old V
+	if blocked, _ := c.writeErr(); blocked {
+		return old, false
+	}
+
+	i, found := c.items[key]
+	c.set(key, data, ttl)
+
+	if !found || c.expired(key, i) {
+		return old, false
+	}
+
+	return i.value, true
+}
+
+// GetAndReset returns the live value stored under key, if any, and stores
+// resetTo in its place with a fresh TTL in the same locked step, so updates
+// made concurrently with the read can't be lost. It is the flush primitive
+// for accumulator-style items such as counters, and behaves like Swap.
+func (c *Cache[K, V]) GetAndReset(key K, resetTo V, ttl time.Duration) (old V, found bool) {
+	return c.Swap(key, resetTo, ttl)
+}
+
+// SetWithDeps inserts an item that depends on the items stored under
+// dependsOn: removing or expiring any of them also removes this item, and in
+// turn its own dependents. Dependencies last until the item is removed; a
+// later Set keeps them, while SetWithDeps replaces them. Cycles are allowed
+// and simply remove every item in the cycle.
+func (c *Cache[K, V]) SetWithDeps(key K, data V, ttl time.Duration, dependsOn ...K) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if blocked, _ := c.writeErr(); blocked {
+		return
+	}
+
+	if !c.set(key, data, ttl) {
+		return
+	}
+
+	if c.dependencies != nil {
+		c.unlink(key)
+	}
+	if len(dependsOn) > 0 {
+		c.link(key, slices.Clone(dependsOn))
+	}
+}
+
+// SetSticky inserts a sticky item, which survives Clear and is exempt from
+// eviction; only ClearAll, Remove and the other explicit removals delete it.
+// A sticky item still expires after ttl like any other. Since sticky items
+// are never evicted, a cache bounded by WithCapacity holding only sticky
+// items grows beyond its capacity, unless WithOverflowPolicy says otherwise.
+// Overwriting the key with Set makes the item regular again.
+func (c *Cache[K, V]) SetSticky(key K, data V, ttl time.Duration) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if blocked, _ := c.writeErr(); blocked {
+		return
+	}
+
+	now := Now()
+	c.store(key, item[V]{
+		value:   data,
+		expiry:  c.expiryAfter(now, ttl),
+		ttl:     ttl,
+		created: now,
+		sticky:  true,
+	})
 }
 
-type item[V any] struct {
-	value  V
-	expiry time.Time
+// SetWithIdle inserts an item that expires after ttl or after going maxIdle
+// without being read by Get, whichever comes first. Each Get restarts the
+// idle clock, but never extends the item beyond its absolute ttl.
+func (c *Cache[K, V]) SetWithIdle(key K, data V, ttl, maxIdle time.Duration) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if blocked, _ := c.writeErr(); blocked {
+		return
+	}
+
+	now := Now()
+	c.store(key, item[V]{
+		value:    data,
+		expiry:   c.expiryAfter(now, ttl),
+		ttl:      ttl,
+		idle:     maxIdle,
+		accessed: now,
+		created:  now,
+	})
+}
+
+// SetSpread inserts items with expiries spaced evenly across the interval
+// [baseTTL, baseTTL+window], so a bulk load doesn't expire all at once and
+// trigger a synchronized reload. Which key receives which offset follows map
+// iteration order and is therefore unspecified.
+func (c *Cache[K, V]) SetSpread(items map[K]V, baseTTL, window time.Duration) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if blocked, _ := c.writeErr(); blocked {
+		return
+	}
+
+	var step time.Duration
+	if len(items) > 1 {
+		step = window / time.Duration(len(items)-1)
+	}
+
+	now, n := Now(), 0
+	for key, data := range items {
+		ttl := baseTTL + time.Duration(n)*step
+		c.store(key, item[V]{
+			value:   data,
+			expiry:  c.expiryAfter(now, ttl),
+			ttl:     ttl,
+			created: now,
+		})
+		n++
+	}
+}
+
+// Pair is a key and value stored by SetPairs.
+type Pair[K comparable, V any] struct {
+	Key   K
+	Value V
+}
+
+// DuplicatePolicy determines which value SetPairs stores for a key that
+// occurs more than once.
+type DuplicatePolicy int
+
+const (
+	// LastWins stores the last value given for the key. This is the
+	// default.
+	LastWins DuplicatePolicy = iota
+
+	// FirstWins stores the first value given for the key.
+	FirstWins
+)
+
+// SetPairs inserts the items of pairs, all with the same TTL, under a single
+// lock. A key occurring more than once is stored only once, with the value
+// chosen by policy.
+func (c *Cache[K, V]) SetPairs(pairs []Pair[K, V], ttl time.Duration, policy DuplicatePolicy) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if blocked, _ := c.writeErr(); blocked {
+		return
+	}
+
+	// winner maps each key to the index of the pair providing its value.
+	winner := make(map[K]int, len(pairs))
+	for n, p := range pairs {
+		if _, seen := winner[p.Key]; !seen || policy == LastWins {
+			winner[p.Key] = n
+		}
+	}
+
+	for n, p := range pairs {
+		if winner[p.Key] == n {
+			c.set(p.Key, p.Value, ttl)
+		}
+	}
+}
+
+// Add inserts an item into the cache if no existing item is associated
+// with the given key or if the current item has expired. If an active
+// item exists for the key, it returns an error indicating that the item cannot
+// be added. A validator set with WithSetValidator is consulted first.
+func (c *Cache[K, V]) Add(key K, data V, ttl time.Duration) error {
+
+	if err := c.check(key, data); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if blocked, err := c.writeErr(); blocked {
+		return err
+	}
+
+	if item, found := c.items[key]; found {
+
+		if c.expired(key, item) {
+			c.expire(key)
+		} else {
+			return fmt.Errorf("item %v already exists", key)
+		}
+	}
+
+	if !c.set(key, data, ttl) {
+		return ErrFull
+	}
+
+	return nil
+}
+
+// LoadOrStore returns the live value stored under key with loaded set to
+// true. Otherwise, it stores value with the given ttl and returns it with
+// loaded set to false. It mirrors sync.Map.LoadOrStore. If c rejects the
+// write because it is closed or frozen, or because the overflow policy
+// rejects the new key, nothing is stored but value is still returned with
+// loaded set to false; use Add to learn whether the write took place.
+func (c *Cache[K, V]) LoadOrStore(key K, value V, ttl time.Duration) (actual V, loaded bool) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if i, found := c.items[key]; found && !c.expired(key, i) {
+		return c.clone(i.value), true
+	}
+
+	if blocked, _ := c.writeErr(); !blocked {
+		c.set(key, value, ttl)
+	}
+
+	return value, false
+}
+
+// CompareAndSwap stores data under key with the given ttl if the live value
+// stored there equals old, and reports whether it did. Values are compared
+// with the function set by WithEquals, or with == otherwise, which panics if
+// V is not comparable at run time.
+func (c *Cache[K, V]) CompareAndSwap(key K, old, data V, ttl time.Duration) bool {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if blocked, _ := c.writeErr(); blocked {
+		return false
+	}
+
+	i, found := c.items[key]
+	if !found || c.expired(key, i) || !c.equal(i.value, old) {
+		return false
+	}
+
+	c.set(key, data, ttl)
+	return true
+}
+
+// CompareAndDelete deletes the item stored under key if its live value
+// equals old, and reports whether it did. Values are compared as in
+// CompareAndSwap.
+func (c *Cache[K, V]) CompareAndDelete(key K, old V) bool {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if blocked, _ := c.writeErr(); blocked {
+		return false
+	}
+
+	i, found := c.items[key]
+	if !found || c.expired(key, i) || !c.equal(i.value, old) {
+		return false
+	}
+
+	c.delete(key)
+	return true
+}
+
+// Replace updates the value for a cache key only if the key already exists
+// and the associated item has not expired. If the item has expired, it
+// attempts to delete it and returns an error indicating that the value
+// cannot be replaced, unless WithReplaceExpiredPolicy selects another
+// behavior. A validator set with WithSetValidator is consulted first.
+func (c *Cache[K, V]) Replace(key K, data V, ttl time.Duration) error {
+
+	if err := c.check(key, data); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if blocked, err := c.writeErr(); blocked {
+		return err
+	}
+
+	return c.replace(key, data, ttl)
+}
+
+// Update atomically transforms the value stored under key. The function f
+// receives the current value and whether a live item was found, and returns
+// the new value along with a keep flag. If keep is false, the item is deleted.
+// An existing item keeps its expiry; a newly created one expires after ttl.
+// The write lock is held while f runs, so f must not call back into the cache.
+// Update reports whether the key holds a value after the call.
+func (c *Cache[K, V]) Update(key K, ttl time.Duration, f func(old V, found bool) (V, bool)) bool {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if blocked, _ := c.writeErr(); blocked {
+		return false
+	}
+
+	i, found := c.items[key]
+	if found && c.expired(key, i) {
+		c.expire(key)
+		i, found = item[V]{}, false
+	}
+
+	value, keep := f(i.value, found)
+	if !keep {
+		c.delete(key)
+		return false
+	}
+
+	if found {
+		i.value = value
+		return c.store(key, i)
+	}
+
+	return c.set(key, value, ttl)
+}
+
+// Append atomically appends vals to the slice stored under key, starting a
+// new slice if the key is missing or expired. Every call resets the item's
+// TTL, so a key that keeps receiving values stays alive until ttl elapses
+// without an append.
+func Append[K comparable, T any](c *Cache[K, []T], key K, ttl time.Duration, vals ...T) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if blocked, _ := c.writeErr(); blocked {
+		return
+	}
+
+	var values []T
+	if i, found := c.items[key]; found && !c.expired(key, i) {
+		values = i.value
+	}
+
+	c.set(key, append(values, vals...), ttl)
+}
+
+// Number is the set of types supported by IncrementOrSet.
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// IncrementOrSet atomically adds delta to the number stored under key and
+// returns the new total, leaving the item's expiry unchanged. If the key is
+// missing or expired, it stores initial instead, expiring after ttl, and
+// returns it; delta is not added to it. This suits rate limiters seeding a
+// counter for each window. It returns the zero value if c rejects writes
+// because it is closed or frozen, or if the overflow policy rejects the new
+// key.
+func IncrementOrSet[K comparable, V Number](c *Cache[K, V], key K, delta, initial V, ttl time.Duration) V {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if blocked, _ := c.writeErr(); blocked {
+		return 0
+	}
+
+	i, found := c.items[key]
+	if !found || c.expired(key, i) {
+		if !c.set(key, initial, ttl) {
+			return 0
+		}
+		return initial
+	}
+
+	i.value += delta
+	c.store(key, i)
+
+	return i.value
+}
+
+// Move atomically transfers the live item stored under key from src to dst,
+// reporting whether it was moved. With a positive ttl, the item expires ttl
+// after the move; otherwise it keeps its expiry and idle timeout. Nothing is
+// moved if src or dst rejects writes because it is closed or frozen, or if
+// dst is full and its overflow policy rejects the key.
+//
+// Both caches are locked for the duration of the move, always in the order
+// they were created, so concurrent moves in opposite directions can't
+// deadlock. Callers holding one cache's lock, as in Do, must not call Move.
+func Move[K comparable, V any](src, dst *Cache[K, V], key K, ttl time.Duration) bool {
+
+	if src == dst {
+		return src.Has(key)
+	}
+
+	first, second := src, dst
+	if second.id < first.id {
+		first, second = second, first
+	}
+
+	first.mu.Lock()
+	defer first.mu.Unlock()
+	second.mu.Lock()
+	defer second.mu.Unlock()
+
+	if blocked, _ := src.writeErr(); blocked {
+		return false
+	}
+	if blocked, _ := dst.writeErr(); blocked {
+		return false
+	}
+
+	i, found := src.items[key]
+	if !found || src.expired(key, i) || !dst.room(key) {
+		return false
+	}
+
+	src.delete(key)
+
+	if ttl > 0 {
+		now := Now()
+		i.expiry, i.ttl = dst.expiryAfter(now, ttl), ttl
+		if i.idle > 0 {
+			i.accessed = now
+		}
+	}
+	dst.store(key, i)
+
+	return true
+}
+
+// Get retrieves the value associated with the specified key from the cache.
+// It returns the item value along with a boolean indicating whether the key
+// was found. If the key is expired, it is deleted from the cache, and the
+// function returns false. When a loader is configured with WithLoader, a
+// missing or expired key is loaded and stored instead, and Get returns false
+// only if the loader fails.
+//
+// A miss returns the zero value, which is indistinguishable from a stored
+// zero value, e.g. a counter at 0. The boolean is the only reliable signal of
+// presence; use MustGet where presence is guaranteed.
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+
+	value, source, _ := c.GetWithSource(key)
+	return value, source != SourceMiss
+}
+
+// MustGet retrieves the value stored under key like Get, but panics if
+// there is none. It is meant for code paths that guarantee the key is
+// present, where a miss is a programming error.
+func (c *Cache[K, V]) MustGet(key K) V {
+
+	value, found := c.Get(key)
+	if !found {
+		panic(fmt.Errorf("item %v doesn't exist", key))
+	}
+
+	return value
+}
+
+// GetAndMaybeExtend retrieves the value stored under key like Get and, if
+// the item has less than threshold left to live, resets its expiry to ttl
+// from now. Frequently read items thus stay cached without extending the
+// item on every read. The read counts once toward WithMaxRenewals, even if
+// it also restarts the idle timeout of an item stored with SetWithIdle.
+func (c *Cache[K, V]) GetAndMaybeExtend(key K, ttl, threshold time.Duration) (V, bool) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	renewals := c.items[key].renewals
+
+	value, found := c.lookup(key)
+	if !found {
+		return value, false
+	}
+
+	// A read restarting the idle timeout has already been counted as a
+	// renewal by lookup, and a single read uses a single renewal.
+	now := Now()
+	if i := c.items[key]; i.remaining(now) < threshold && !c.closed && !c.frozen && (i.renewals > renewals || c.renew(&i)) {
+		i.expiry = c.expiryAfter(now, ttl)
+		c.items[key] = i
+
+		if c.policy != nil && !i.sticky {
+			c.policy.Touch(key, i.deadline())
+		}
+	}
+
+	return c.clone(value), true
 }
 
-// New initializes a new Cache instance and launches a goroutine
-// that periodically removes expired items from the cache based on the
-// specified cleanupInterval.
-func New[K comparable, V any](cleanupInterval time.Duration) *Cache[K, V] {
+// GetAndAdjustTTL retrieves the value stored under key like Get and sets the
+// item's remaining time to live to the duration returned by adjust, which
+// receives the current remaining time, math.MaxInt64 if the item never
+// expires, and the value. This lets a read shorten the life of a value it
+// finds less trustworthy. If adjust returns a non-positive duration, the
+// item expires right away, although this read still returns it. The item's
+// TTL, used by sliding expiration, is left unchanged.
+func (c *Cache[K, V]) GetAndAdjustTTL(key K, adjust func(remaining time.Duration, value V) time.Duration) (V, bool) {
 
-	c := &Cache[K, V]{
-		items: make(map[K]item[V]),
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	value, found := c.lookup(key)
+	if !found {
+		return value, false
 	}
 
-	go func() {
+	if c.closed || c.frozen {
+		return c.clone(value), true
+	}
 
-		for range time.Tick(cleanupInterval) {
+	now := Now()
+	i := c.items[key]
 
-			c.mu.Lock()
+	d := adjust(i.remaining(now), value)
+	if d <= 0 {
+		c.expire(key)
+		return c.clone(value), true
+	}
 
-			for k, item := range c.items {
-				if item.isExpired() {
-					c.delete(k)
-				}
-			}
+	i.expiry = c.expiryAfter(now, d)
+	c.items[key] = i
 
-			c.mu.Unlock()
-		}
-	}()
+	if c.policy != nil && !i.sticky {
+		c.policy.Touch(key, i.deadline())
+	}
 
-	return c
+	return c.clone(value), true
 }
 
-// Set inserts an item to the cache, replacing any existing one.
-func (c *Cache[K, V]) Set(key K, data V, ttl time.Duration) {
+// SetVersioned inserts an item like Set and returns the version assigned to
+// it. Versions come from a counter shared by the whole cache and increase on
+// every write, so a higher version always denotes a more recent write to the
+// key. It returns 0 if the write is dropped.
+func (c *Cache[K, V]) SetVersioned(key K, data V, ttl time.Duration) uint64 {
 
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.set(key, data, ttl)
+	if blocked, _ := c.writeErr(); blocked {
+		return 0
+	}
+
+	if !c.set(key, data, ttl) {
+		return 0
+	}
+
+	return c.items[key].version
 }
 
-// Add inserts an item into the cache if no existing item is associated
-// with the given key or if the current item has expired. If an active
-// item exists for the key, it returns an error indicating that the item cannot
-// be added.
-func (c *Cache[K, V]) Add(key K, data V, ttl time.Duration) error {
+// GetVersioned retrieves the value associated with key along with its
+// version. Like Get, it deletes an expired item and returns false.
+func (c *Cache[K, V]) GetVersioned(key K) (V, uint64, bool) {
 
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	value, found := c.lookup(key)
+	version := c.items[key].version
+	c.mu.Unlock()
 
-	if item, found := c.items[key]; found {
+	c.observeRead(key, found)
 
-		if item.isExpired() {
-			c.delete(key)
-		} else {
-			return fmt.Errorf("item %v already exists", key)
+	if !found {
+		return value, 0, false
+	}
+
+	return c.clone(value), version, true
+}
+
+// GetOrdered retrieves the values for keys under a single read lock. The
+// returned slices are aligned with keys: a missing or expired key gets the
+// zero value and false at its position. Expired items are left in place.
+func (c *Cache[K, V]) GetOrdered(keys []K) ([]V, []bool) {
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	values := make([]V, len(keys))
+	found := make([]bool, len(keys))
+
+	for n, key := range keys {
+		c.counters.count(&c.counters.gets)
+		if i, ok := c.items[key]; ok && !c.expired(key, i) {
+			values[n], found[n] = c.clone(i.value), true
 		}
 	}
 
-	c.set(key, data, ttl)
-	return nil
+	return values, found
 }
 
-// Replace updates the value for a cache key only if the key already exists
-// and the associated item has not expired. If the item has expired, it
-// attempts to delete it and returns an error indicating that the value
-// cannot be replaced.
-func (c *Cache[K, V]) Replace(key K, data V, ttl time.Duration) error {
+// Peek retrieves the value stored under key without counting a hit,
+// refreshing an idle timeout or informing the eviction policy. Expired
+// items are reported as missing and left in place.
+func (c *Cache[K, V]) Peek(key K) (V, bool) {
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	c.counters.count(&c.counters.gets)
+
+	i, found := c.items[key]
+	if !found || c.expired(key, i) {
+		var zero V
+		return zero, false
+	}
+
+	return c.clone(i.value), true
+}
+
+// TryGet retrieves the value stored under key like Peek, unless the lock is
+// held by a writer or a writer is waiting for it, in which case it returns
+// right away with false for locked, so latency-critical callers can fall
+// back to the source rather than wait out a write burst.
+func (c *Cache[K, V]) TryGet(key K) (value V, found, locked bool) {
+
+	if !c.mu.TryRLock() {
+		return value, false, false
+	}
+	defer c.mu.RUnlock()
+
+	c.counters.count(&c.counters.gets)
+
+	i, found := c.items[key]
+	if !found || c.expired(key, i) {
+		return value, false, true
+	}
+
+	return c.clone(i.value), true, true
+}
+
+// ItemInfo describes a stored item, as returned by Inspect. The zero Expiry
+// means the item never expires.
+type ItemInfo[V any] struct {
+	Value   V
+	Created time.Time
+	Expiry  time.Time
+	TTL     time.Duration
+	Hits    uint64
+	Version uint64
+
+	// Size is the size of the item as estimated by the function set with
+	// WithSizeFunc, or zero without one.
+	Size int64
+}
+
+// Age returns how long ago the item was created.
+func (info ItemInfo[V]) Age() time.Duration {
+	return Now().Sub(info.Created)
+}
+
+// Inspect returns the metadata of the live item stored under key, without
+// counting a hit or otherwise affecting it, like Peek.
+func (c *Cache[K, V]) Inspect(key K) (ItemInfo[V], bool) {
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	i, found := c.items[key]
+	if !found || c.expired(key, i) {
+		return ItemInfo[V]{}, false
+	}
+
+	return ItemInfo[V]{
+		Value:   c.clone(i.value),
+		Created: i.created,
+		Expiry:  i.deadline(),
+		TTL:     i.ttl,
+		Hits:    i.hits,
+		Version: i.version,
+		Size:    i.size,
+	}, true
+}
+
+// Has reports whether a live item is stored under key. Like Peek, it leaves
+// the item's statistics and expired items untouched.
+func (c *Cache[K, V]) Has(key K) bool {
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	c.counters.count(&c.counters.gets)
+
+	i, found := c.items[key]
+	return found && !c.expired(key, i)
+}
+
+// Len returns the number of live items in the cache.
+func (c *Cache[K, V]) Len() int {
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	n := 0
+	for key, i := range c.items {
+		if !c.expired(key, i) {
+			n++
+		}
+	}
+
+	return n
+}
+
+// GetOrSetWithStatus returns the live value stored under key, reporting
+// false for computed, or else stores the result of f with ttl and returns it,
+// reporting true. The key is checked again under the write lock before f is
+// called, so f runs at most once per miss and is never called for a key
+// another goroutine stored in the meantime. Since f runs with the lock held,
+// it must not call back into the cache. The result of f is still returned
+// when the cache rejects writes because it is closed or frozen.
+func (c *Cache[K, V]) GetOrSetWithStatus(key K, ttl time.Duration, f func() V) (value V, computed bool) {
+
+	if value, found := c.get(key); found {
+		return c.clone(value), false
+	}
 
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if i, found := c.items[key]; found {
+	if i, found := c.items[key]; found && !c.expired(key, i) {
+		return c.clone(i.value), false
+	}
+
+	value = f()
+	if blocked, _ := c.writeErr(); !blocked {
+		c.set(key, value, ttl)
+	}
+
+	return c.clone(value), true
+}
+
+// GetOrSetMany returns the values stored under keys, computing the missing
+// or expired ones with factory and storing them with ttl. The factory runs
+// without holding the lock, so a slow factory doesn't stall other callers;
+// if another goroutine stores a key meanwhile, that value is kept and
+// returned instead of the computed one. Computed values are still returned
+// when the cache rejects writes because it is closed or frozen.
+func (c *Cache[K, V]) GetOrSetMany(keys []K, ttl time.Duration, factory func(key K) V) map[K]V {
+
+	values := make(map[K]V, len(keys))
+	var missing []K
 
-		if i.isExpired() {
-			c.delete(key)
-			return fmt.Errorf("item %v is expired", key)
+	c.mu.Lock()
+	for _, key := range keys {
+		if value, found := c.lookup(key); found {
+			values[key] = c.clone(value)
 		} else {
-			c.set(key, data, ttl)
-			return nil
+			missing = append(missing, key)
 		}
 	}
+	c.mu.Unlock()
 
-	return fmt.Errorf("item %v doesn't exist", key)
-}
+	if len(missing) == 0 {
+		return values
+	}
 
-// Get retrieves the value associated with the specified key from the cache.
-// It returns the item value along with a boolean indicating whether the key
-// was found. If the key is expired, it is deleted from the cache, and the
-// function returns false.
-func (c *Cache[K, V]) Get(key K) (V, bool) {
+	computed := make(map[K]V, len(missing))
+	for _, key := range missing {
+		if _, done := computed[key]; !done {
+			computed[key] = factory(key)
+		}
+	}
 
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	i, found := c.items[key]
-	if !found {
-		return i.value, false
-	}
-	if i.isExpired() {
-		c.delete(key)
-		return i.value, false
+	blocked, _ := c.writeErr()
+	for key, value := range computed {
+		if !blocked {
+			if i, found := c.items[key]; found && !c.expired(key, i) {
+				value = i.value
+			} else {
+				c.set(key, value, ttl)
+			}
+		}
+		values[key] = c.clone(value)
 	}
 
-	return i.value, true
+	return values
 }
 
 // Pop deletes and returns the item associated with the specified key from the cache.
@@ -128,49 +1213,344 @@ func (c *Cache[K, V]) Pop(key K) (V, bool) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	if blocked, _ := c.writeErr(); blocked {
+		var zero V
+		return zero, false
+	}
+
 	i, found := c.items[key]
 	if !found {
 		return i.value, false
 	}
 
+	if c.expired(key, i) {
+		c.expire(key)
+		return i.value, false
+	}
+
 	c.delete(key)
 
-	if i.isExpired() {
-		return i.value, false
+	return c.clone(i.value), true
+}
+
+// PopWithExpiry deletes and returns the item associated with key like Pop,
+// additionally returning its absolute expiry so the deadline can be kept
+// when the value is stored elsewhere.
+func (c *Cache[K, V]) PopWithExpiry(key K) (V, time.Time, bool) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if blocked, _ := c.writeErr(); blocked {
+		var zero V
+		return zero, time.Time{}, false
 	}
 
-	return i.value, true
+	i, found := c.items[key]
+	if !found {
+		return i.value, time.Time{}, false
+	}
+
+	if c.expired(key, i) {
+		c.expire(key)
+		var zero V
+		return zero, time.Time{}, false
+	}
+
+	c.delete(key)
+
+	return c.clone(i.value), i.deadline(), true
+}
+
+// LoadAndDelete deletes the item stored under key, returning its value and
+// whether it was live. It mirrors sync.Map.LoadAndDelete and behaves like
+// Pop.
+func (c *Cache[K, V]) LoadAndDelete(key K) (value V, loaded bool) {
+	return c.Pop(key)
 }
 
 // Remove removes the item associated with the specified key from the cache.
 // If the key exists, the item is permanently deleted; if the key is not found,
-// no action is taken.
+// no action is taken. With WithTombstones, removing an existing key leaves a
+// tombstone that is visible only through Export.
 func (c *Cache[K, V]) Remove(key K) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.delete(key)
+	if blocked, _ := c.writeErr(); blocked {
+		return
+	}
+
+	c.erase(key)
 }
 
 // RemoveExpired removes all expired items from the cache.
 func (c *Cache[K, V]) RemoveExpired() {
+	c.RemoveExpiredN()
+}
+
+// RemoveExpiredN removes all expired items from the cache and returns the
+// number of items removed.
+func (c *Cache[K, V]) RemoveExpiredN() int {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if blocked, _ := c.writeErr(); blocked {
+		return 0
+	}
+
+	removed := 0
+	for key, i := range c.items {
+		if c.expired(key, i) {
+			c.expire(key)
+			removed++
+		}
+	}
+
+	return removed
+}
+
+// Compact removes the expired items and rebuilds the internal map from the
+// remaining ones, releasing the memory a map keeps after its items are
+// deleted. It copies every item under the write lock, so it is best called
+// after known mass removals rather than routinely. While the cache is
+// frozen, expired items are copied too.
+func (c *Cache[K, V]) Compact() {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+
+	if !c.frozen {
+		for key, i := range c.items {
+			if c.expired(key, i) {
+				c.expire(key)
+			}
+		}
+	}
+
+	c.compact()
+}
+
+// removeChunk is the number of keys RemoveExpiredAsync checks per lock
+// acquisition.
+const removeChunk = 1024
+
+// RemoveExpiredAsync starts removing all expired items in the background and
+// returns immediately, reporting whether a pass was started. The pass checks
+// items in small chunks, releasing the lock in between, so readers aren't
+// stalled for the whole pass. Only one pass runs at a time; calls made while
+// one is running do nothing and return false.
+func (c *Cache[K, V]) RemoveExpiredAsync() bool {
+
+	if !c.asyncSweep.CompareAndSwap(false, true) {
+		return false
+	}
+
+	go func() {
+		defer c.asyncSweep.Store(false)
+
+		c.mu.RLock()
+		keys := make([]K, 0, len(c.items))
+		for key := range c.items {
+			keys = append(keys, key)
+		}
+		c.mu.RUnlock()
+
+		for len(keys) > 0 {
+			n := min(len(keys), removeChunk)
+
+			c.mu.Lock()
+			if c.closed || c.frozen {
+				c.mu.Unlock()
+				return
+			}
+			for _, key := range keys[:n] {
+				if i, found := c.items[key]; found && c.expired(key, i) {
+					c.expire(key)
+				}
+			}
+			c.mu.Unlock()
+
+			keys = keys[n:]
+		}
+	}()
+
+	return true
+}
+
+// CountWhere returns the number of live items matching pred, under a single
+// read lock and without copying them. The predicate runs under the lock and
+// must not call back into the cache.
+func (c *Cache[K, V]) CountWhere(pred func(key K, value V) bool) int {
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	n := 0
+	for key, i := range c.items {
+		if !c.expired(key, i) && pred(key, i.value) {
+			n++
+		}
+	}
+
+	return n
+}
+
+// SetTTLWhere resets the expiry of every live item matching pred to ttl
+// from now, under a single write lock, and returns the number of items
+// updated. The predicate runs under the lock and must not call back into
+// the cache.
+func (c *Cache[K, V]) SetTTLWhere(pred func(key K, value V) bool, ttl time.Duration) int {
 
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	if blocked, _ := c.writeErr(); blocked {
+		return 0
+	}
+
+	expiry, updated := c.expiryAfter(Now(), ttl), 0
+	for key, i := range c.items {
+		if !c.expired(key, i) && pred(key, i.value) {
+			i.expiry, i.ttl = expiry, ttl
+			c.store(key, i)
+			updated++
+		}
+	}
+
+	return updated
+}
+
+// Keys returns the keys of all live items, in no particular order unless a
+// comparator is configured with WithKeyComparator.
+func (c *Cache[K, V]) Keys() []K {
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	keys := make([]K, 0, len(c.items))
+	for key, i := range c.items {
+		if !c.expired(key, i) {
+			keys = append(keys, key)
+		}
+	}
+
+	if c.compareKeys != nil {
+		slices.SortFunc(keys, c.compareKeys)
+	}
+
+	return keys
+}
+
+// ExpiredKeys returns the keys of the items that have expired but are still
+// stored, waiting for a cleanup pass or a read to remove them. It doesn't
+// remove anything, so it can be used to observe cleanup lag. Like Keys, the
+// keys are ordered by the comparator configured with WithKeyComparator.
+func (c *Cache[K, V]) ExpiredKeys() []K {
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var keys []K
+	for key, i := range c.items {
+		if c.expired(key, i) {
+			keys = append(keys, key)
+		}
+	}
+
+	if c.compareKeys != nil {
+		slices.SortFunc(keys, c.compareKeys)
+	}
+
+	return keys
+}
+
+// SortedKeys returns the keys of all live items of c in ascending order.
+func SortedKeys[K cmp.Ordered, V any](c *Cache[K, V]) []K {
+
+	keys := c.Keys()
+	slices.Sort(keys)
+
+	return keys
+}
+
+// TTLHistogram bins the live items by their remaining time to live. Each item
+// is counted under the smallest bucket boundary that is greater than or equal
+// to its remaining TTL. Items outliving the largest boundary are counted under
+// math.MaxInt64. Expired items are skipped and left in place.
+func (c *Cache[K, V]) TTLHistogram(buckets []time.Duration) map[time.Duration]int {
+
+	bounds := slices.Clone(buckets)
+	slices.Sort(bounds)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	histogram := make(map[time.Duration]int, len(bounds)+1)
+	now := Now()
+
 	for key, i := range c.items {
-		if i.isExpired() {
-			c.delete(key)
+		remaining := i.remaining(now)
+		if remaining < 0 || c.expired(key, i) {
+			continue
+		}
+
+		bucket := time.Duration(math.MaxInt64)
+		if n, _ := slices.BinarySearch(bounds, remaining); n < len(bounds) {
+			bucket = bounds[n]
 		}
+		histogram[bucket]++
 	}
 
+	return histogram
 }
 
-// Clear clears the cache, removing all items.
+// MemoryUsage returns an estimate of the bytes held by the cached items. With
+// a size function configured through WithSizeFunc, it returns the sum of the
+// sizes reported for the stored items, maintained incrementally on every
+// write. Otherwise it returns the item count multiplied by the in-memory
+// size of a key and an item header, which ignores any memory referenced by
+// the values themselves.
+func (c *Cache[K, V]) MemoryUsage() int64 {
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.sizeOf != nil {
+		return c.bytes
+	}
+
+	return int64(len(c.items)) * estimatedItemSize[K, V]()
+}
+
+// Clear clears the cache, removing all items except the sticky ones stored
+// with SetSticky, as well as all tombstones and dependencies between items.
 func (c *Cache[K, V]) Clear() {
 
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	clear(c.items)
+	if blocked, _ := c.writeErr(); blocked {
+		return
+	}
+
+	c.clear(true)
+}
+
+// ClearAll clears the cache like Clear, removing sticky items too.
+func (c *Cache[K, V]) ClearAll() {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if blocked, _ := c.writeErr(); blocked {
+		return
+	}
+
+	c.clear(false)
 }
@@ -1,8 +1,16 @@
 package cache
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -61,6 +69,80 @@ func TestCacheAdd(t *testing.T) {
 
 }
 
+func TestCacheReplacePreservesTTL(t *testing.T) {
+
+	t.Parallel()
+
+	c := New(1*time.Second, WithReplacePreservesTTL[string, int](true))
+
+	c.Set("key1", 10, 5*time.Second)
+	before, _ := c.Inspect("key1")
+
+	time.Sleep(10 * time.Millisecond)
+
+	// should replace the value but keep the expiry.
+	if err := c.Replace("key1", 20, 1*time.Minute); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	after, _ := c.Inspect("key1")
+	if after.Value != 20 || !after.Expiry.Equal(before.Expiry) {
+		t.Fatalf("expected 20 expiring at %v, but got %v expiring at %v", before.Expiry, after.Value, after.Expiry)
+	}
+	if !after.Created.After(before.Created) {
+		t.Fatalf("expected a creation time after %v, but got %v", before.Created, after.Created)
+	}
+
+	// should still reset the expiry on Set.
+	c.Set("key1", 30, 1*time.Minute)
+
+	if after, _ := c.Inspect("key1"); !after.Expiry.After(before.Expiry) {
+		t.Fatalf("expected an expiry after %v, but got %v", before.Expiry, after.Expiry)
+	}
+}
+
+func TestCacheSetValidator(t *testing.T) {
+
+	t.Parallel()
+
+	errNegative := errors.New("negative value")
+
+	c := New(1*time.Second, WithSetValidator[string, int](func(key string, value int) error {
+		if value < 0 {
+			return errNegative
+		}
+		return nil
+	}))
+
+	// should reject invalid values without storing them.
+	if err := c.SetChecked("key1", -1, 5*time.Second); !errors.Is(err, errNegative) {
+		t.Fatalf("expected errNegative, but got %v", err)
+	}
+	if err := c.Add("key1", -1, 5*time.Second); !errors.Is(err, errNegative) {
+		t.Fatalf("expected errNegative, but got %v", err)
+	}
+	if _, found := c.Get("key1"); found {
+		t.Fatal("expected invalid value not to be stored")
+	}
+
+	if err := c.SetChecked("key1", 10, 5*time.Second); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if err := c.Replace("key1", -1, 5*time.Second); !errors.Is(err, errNegative) {
+		t.Fatalf("expected errNegative, but got %v", err)
+	}
+	if value, found := c.Get("key1"); !found || value != 10 {
+		t.Fatalf("expected 10, but got %v, found: %v", value, found)
+	}
+
+	// should bypass the validator.
+	c.Set("key2", -1, 5*time.Second)
+
+	if value, found := c.Get("key2"); !found || value != -1 {
+		t.Fatalf("expected -1, but got %v, found: %v", value, found)
+	}
+}
+
 func TestCacheReplace(t *testing.T) {
 
 	t.Parallel()
@@ -93,6 +175,39 @@ func TestCacheReplace(t *testing.T) {
 	}
 }
 
+func TestCacheReplaceExpiredPolicy(t *testing.T) {
+
+	t.Parallel()
+
+	set := New(1*time.Second,
+		WithManualCleanup[string, int](),
+		WithReplaceExpiredPolicy[string, int](ReplaceExpiredSet),
+	)
+	missing := New(1*time.Second,
+		WithManualCleanup[string, int](),
+		WithReplaceExpiredPolicy[string, int](ReplaceExpiredMissing),
+	)
+
+	set.Set("key1", 10, 0*time.Second)
+	missing.Set("key1", 10, 0*time.Second)
+
+	// should replace the expired item.
+	if err := set.Replace("key1", 20, 5*time.Second); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if value, found := set.Get("key1"); !found || value != 20 {
+		t.Fatalf("expected 20, but got %v, found: %v", value, found)
+	}
+
+	// should fail without deleting the expired item.
+	if err := missing.Replace("key1", 20, 5*time.Second); err == nil {
+		t.Fatal("expected error for expired item, but got none")
+	}
+	if len(missing.items) != 1 {
+		t.Fatalf("expected the expired item to be kept, but got %d items", len(missing.items))
+	}
+}
+
 func TestCachePop(t *testing.T) {
 
 	t.Parallel()
@@ -221,3 +336,1826 @@ func TestCacheConcurrencySafety(t *testing.T) {
 		}
 	}
 }
+
+func TestCacheUpdate(t *testing.T) {
+
+	t.Parallel()
+
+	c := New[string, []string](1 * time.Second)
+
+	appendTag := func(old []string, found bool) ([]string, bool) {
+		return append(old, "tag"), true
+	}
+
+	if !c.Update("key1", 5*time.Second, appendTag) {
+		t.Fatal("expected item to be created")
+	}
+	c.Update("key1", 5*time.Second, appendTag)
+
+	if value, found := c.Get("key1"); !found || len(value) != 2 {
+		t.Fatalf("expected 2 tags, but got %v, found: %v", value, found)
+	}
+
+	// should delete the item.
+	kept := c.Update("key1", 5*time.Second, func(old []string, found bool) ([]string, bool) {
+		return nil, false
+	})
+	if kept {
+		t.Fatal("expected item to be deleted")
+	}
+	if _, found := c.Get("key1"); found {
+		t.Fatal("expected item to be missing after update")
+	}
+}
+
+func TestCacheTTLHistogram(t *testing.T) {
+
+	t.Parallel()
+
+	c := New[string, int](1 * time.Second)
+
+	c.Set("key1", 1, 500*time.Millisecond)
+	c.Set("key2", 2, 2*time.Second)
+	c.Set("key3", 3, 3*time.Second)
+	c.Set("key4", 4, 1*time.Minute)
+	c.Set("key5", 5, 0*time.Second)
+
+	histogram := c.TTLHistogram([]time.Duration{5 * time.Second, 1 * time.Second})
+
+	if histogram[1*time.Second] != 1 {
+		t.Fatalf("expected 1 item in the 1s bucket, but got %d", histogram[1*time.Second])
+	}
+	if histogram[5*time.Second] != 2 {
+		t.Fatalf("expected 2 items in the 5s bucket, but got %d", histogram[5*time.Second])
+	}
+	if histogram[math.MaxInt64] != 1 {
+		t.Fatalf("expected 1 item in the overflow bucket, but got %d", histogram[math.MaxInt64])
+	}
+}
+
+func TestIncrementOrSet(t *testing.T) {
+
+	t.Parallel()
+
+	c := New[string, int](1 * time.Second)
+
+	// should store the initial value, not the delta, for a missing key.
+	if total := IncrementOrSet(c, "key1", 1, 10, 5*time.Second); total != 10 {
+		t.Fatalf("expected 10, but got %d", total)
+	}
+
+	before, _ := c.Inspect("key1")
+
+	// should add the delta and keep the expiry.
+	if total := IncrementOrSet(c, "key1", 5, 10, 1*time.Minute); total != 15 {
+		t.Fatalf("expected 15, but got %d", total)
+	}
+	if after, _ := c.Inspect("key1"); !after.Expiry.Equal(before.Expiry) {
+		t.Fatalf("expected expiry %v, but got %v", before.Expiry, after.Expiry)
+	}
+
+	c.Set("key2", 100, 0*time.Second)
+
+	// should start over from the initial value once expired.
+	if total := IncrementOrSet(c, "key2", 1, 0, 5*time.Second); total != 0 {
+		t.Fatalf("expected 0, but got %d", total)
+	}
+
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				IncrementOrSet(c, "key3", 1, 1, 5*time.Second)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// should not lose increments under concurrency.
+	if value, found := c.Get("key3"); !found || value != 5000 {
+		t.Fatalf("expected 5000, but got %v, found: %v", value, found)
+	}
+}
+
+func TestCacheAppend(t *testing.T) {
+
+	t.Parallel()
+
+	c := New[string, []int](1 * time.Second)
+
+	Append(c, "key1", 5*time.Second, 1, 2)
+	Append(c, "key1", 5*time.Second, 3)
+
+	if value, found := c.Get("key1"); !found || len(value) != 3 || value[2] != 3 {
+		t.Fatalf("expected [1 2 3], but got %v, found: %v", value, found)
+	}
+
+	// should start a new slice because the key is expired.
+	c.Set("key2", []int{1}, 0*time.Second)
+	Append(c, "key2", 5*time.Second, 2)
+
+	if value, found := c.Get("key2"); !found || len(value) != 1 || value[0] != 2 {
+		t.Fatalf("expected [2], but got %v, found: %v", value, found)
+	}
+}
+
+func TestCacheClose(t *testing.T) {
+
+	t.Parallel()
+
+	c := New[string, int](1 * time.Second)
+
+	c.Set("key1", 10, 5*time.Second)
+
+	if c.IsClosed() {
+		t.Fatal("expected cache to be open")
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	c.Close()
+
+	if !c.IsClosed() {
+		t.Fatal("expected cache to be closed")
+	}
+
+	// should be a no-op.
+	c.Set("key2", 20, 5*time.Second)
+
+	if _, found := c.Get("key2"); found {
+		t.Fatal("expected set after close to be dropped")
+	}
+	if _, found := c.Get("key1"); found {
+		t.Fatal("expected items to be removed on close")
+	}
+	if err := c.Add("key3", 30, 5*time.Second); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed, but got %v", err)
+	}
+}
+
+func TestCacheGetOrdered(t *testing.T) {
+
+	t.Parallel()
+
+	c := New[string, int](1 * time.Second)
+
+	c.Set("key1", 10, 5*time.Second)
+	c.Set("key2", 20, 0*time.Second)
+	c.Set("key3", 30, 5*time.Second)
+
+	values, found := c.GetOrdered([]string{"key3", "key2", "missing", "key1"})
+
+	expected := []int{30, 0, 0, 10}
+	expectedFound := []bool{true, false, false, true}
+
+	for n := range expected {
+		if values[n] != expected[n] || found[n] != expectedFound[n] {
+			t.Fatalf("position %d: expected %v (%v), but got %v (%v)", n, expected[n], expectedFound[n], values[n], found[n])
+		}
+	}
+}
+
+func TestCacheClosedPolicy(t *testing.T) {
+
+	t.Parallel()
+
+	ignoring := New(1*time.Second, WithClosedPolicy[string, int](ClosedIgnore))
+	ignoring.Close()
+
+	if err := ignoring.Add("key1", 10, 5*time.Second); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	panicking := New(1*time.Second, WithClosedPolicy[string, int](ClosedPanic))
+	panicking.Close()
+
+	defer func() {
+		if r := recover(); r != ErrClosed {
+			t.Fatalf("expected panic with ErrClosed, but got %v", r)
+		}
+	}()
+
+	panicking.Set("key1", 10, 5*time.Second)
+}
+
+func TestCacheMemoryUsage(t *testing.T) {
+
+	t.Parallel()
+
+	c := New(1*time.Second, WithSizeFunc(func(key string, value string) int64 {
+		return int64(len(key) + len(value))
+	}))
+
+	c.Set("key1", "value", 5*time.Second)
+	c.Set("key2", "value", 5*time.Second)
+
+	if usage := c.MemoryUsage(); usage != 18 {
+		t.Fatalf("expected 18 bytes, but got %d", usage)
+	}
+
+	// should replace the previous size.
+	c.Set("key1", "v", 5*time.Second)
+	c.Remove("key2")
+
+	if usage := c.MemoryUsage(); usage != 5 {
+		t.Fatalf("expected 5 bytes, but got %d", usage)
+	}
+
+	c.Clear()
+
+	if usage := c.MemoryUsage(); usage != 0 {
+		t.Fatalf("expected 0 bytes, but got %d", usage)
+	}
+
+	estimated := New[string, string](1 * time.Second)
+	estimated.Set("key1", "value", 5*time.Second)
+
+	if usage := estimated.MemoryUsage(); usage <= 0 {
+		t.Fatalf("expected a positive estimate, but got %d", usage)
+	}
+}
+
+func TestCacheManualCleanup(t *testing.T) {
+
+	t.Parallel()
+
+	c := New(10*time.Millisecond, WithManualCleanup[string, int]())
+
+	c.Set("key1", 10, 0*time.Second)
+
+	time.Sleep(50 * time.Millisecond)
+
+	// should still be stored because no background sweep ran.
+	if len(c.items) != 1 {
+		t.Fatalf("expected 1 stored item, but got %d", len(c.items))
+	}
+
+	c.RunCleanup()
+
+	if len(c.items) != 0 {
+		t.Fatalf("expected 0 stored items, but got %d", len(c.items))
+	}
+}
+
+func TestCacheSwap(t *testing.T) {
+
+	t.Parallel()
+
+	c := New[string, int](1 * time.Second)
+
+	if _, had := c.Swap("key1", 10, 5*time.Second); had {
+		t.Fatal("expected no previous value")
+	}
+
+	if old, had := c.Swap("key1", 20, 5*time.Second); !had || old != 10 {
+		t.Fatalf("expected previous value 10, but got %v, had: %v", old, had)
+	}
+
+	// should report the expired value as absent.
+	c.Set("key2", 30, 0*time.Second)
+	if _, had := c.Swap("key2", 40, 5*time.Second); had {
+		t.Fatal("expected expired value to be reported as absent")
+	}
+
+	if value, found := c.Get("key2"); !found || value != 40 {
+		t.Fatalf("expected 40, but got %v, found: %v", value, found)
+	}
+}
+
+func TestCacheCleanupObserver(t *testing.T) {
+
+	t.Parallel()
+
+	var report CleanupReport
+
+	c := New(1*time.Second,
+		WithName[string, int]("sessions"),
+		WithManualCleanup[string, int](),
+		WithCleanupObserver[string, int](func(r CleanupReport) {
+			report = r
+		}),
+	)
+
+	c.Set("key1", 10, 0*time.Second)
+	c.Set("key2", 20, 5*time.Second)
+
+	c.RunCleanup()
+
+	if report.Name != "sessions" || report.Scanned != 2 || report.Expired != 1 {
+		t.Fatalf("expected sessions with 2 scanned and 1 expired, but got %+v", report)
+	}
+}
+
+func TestCacheVersioned(t *testing.T) {
+
+	t.Parallel()
+
+	c := New[string, int](1 * time.Second)
+
+	first := c.SetVersioned("key1", 10, 5*time.Second)
+	second := c.SetVersioned("key1", 20, 5*time.Second)
+
+	if second <= first {
+		t.Fatalf("expected version to increase, but got %d after %d", second, first)
+	}
+
+	// should be bumped by a plain Set as well.
+	c.Set("key1", 30, 5*time.Second)
+
+	value, version, found := c.GetVersioned("key1")
+	if !found || value != 30 || version <= second {
+		t.Fatalf("expected 30 with a version above %d, but got %v (version %d), found: %v", second, value, version, found)
+	}
+
+	lru := NewLRU[string, int](2)
+
+	lru.Set("key1", 10, 0)
+	lru.Set("key2", 20, 0)
+	lru.GetVersioned("key1")
+	lru.Set("key3", 30, 0)
+
+	// should count as a use for the eviction policy.
+	if _, _, found := lru.GetVersioned("key1"); !found {
+		t.Fatal("expected key1 to survive after being read")
+	}
+	if _, found := lru.Peek("key2"); found {
+		t.Fatal("expected key2 to be evicted")
+	}
+}
+
+func TestCacheSetPairs(t *testing.T) {
+
+	t.Parallel()
+
+	pairs := []Pair[string, int]{
+		{Key: "key1", Value: 10},
+		{Key: "key2", Value: 20},
+		{Key: "key1", Value: 11},
+	}
+
+	tests := []struct {
+		policy   DuplicatePolicy
+		expected int
+	}{
+		{LastWins, 11},
+		{FirstWins, 10},
+	}
+
+	for _, tt := range tests {
+		c := New(1*time.Second, WithOpCounting[string, int]())
+
+		c.SetPairs(pairs, 5*time.Second, tt.policy)
+
+		// should store the value chosen by the policy, once per key.
+		if value, found := c.Get("key1"); !found || value != tt.expected {
+			t.Fatalf("policy %d: expected %d, but got %v, found: %v", tt.policy, tt.expected, value, found)
+		}
+		if value, found := c.Get("key2"); !found || value != 20 {
+			t.Fatalf("policy %d: expected 20, but got %v, found: %v", tt.policy, value, found)
+		}
+		if sets := c.Stats().Sets; sets != 2 {
+			t.Fatalf("policy %d: expected 2 sets, but got %d", tt.policy, sets)
+		}
+	}
+}
+
+func TestCacheSetSpread(t *testing.T) {
+
+	t.Parallel()
+
+	c := New[string, int](1 * time.Second)
+
+	items := make(map[string]int)
+	for i := 0; i < 5; i++ {
+		items[fmt.Sprintf("key%d", i)] = i
+	}
+
+	c.SetSpread(items, 10*time.Second, 4*time.Second)
+
+	c.mu.RLock()
+	expiries := make([]time.Time, 0, len(c.items))
+	for _, i := range c.items {
+		expiries = append(expiries, i.expiry)
+	}
+	c.mu.RUnlock()
+
+	slices.SortFunc(expiries, time.Time.Compare)
+
+	for n := 1; n < len(expiries); n++ {
+		if gap := expiries[n].Sub(expiries[n-1]); gap != 1*time.Second {
+			t.Fatalf("expected expiries to be 1s apart, but got %v", gap)
+		}
+	}
+	if window := expiries[len(expiries)-1].Sub(expiries[0]); window != 4*time.Second {
+		t.Fatalf("expected expiries to span 4s, but got %v", window)
+	}
+}
+
+func TestCacheName(t *testing.T) {
+
+	t.Parallel()
+
+	a := New[string, int](1 * time.Second)
+	b := New[string, int](1 * time.Second)
+
+	if a.Name() == "" || a.Name() == b.Name() {
+		t.Fatalf("expected distinct generated names, but got %q and %q", a.Name(), b.Name())
+	}
+
+	named := New(1*time.Second, WithName[string, int]("users"))
+	if named.Name() != "users" {
+		t.Fatalf("expected users, but got %q", named.Name())
+	}
+}
+
+func TestCacheLoadOrStore(t *testing.T) {
+
+	t.Parallel()
+
+	c := New[string, int](1 * time.Second)
+
+	if actual, loaded := c.LoadOrStore("key1", 10, 5*time.Second); loaded || actual != 10 {
+		t.Fatalf("expected to store 10, but got %v, loaded: %v", actual, loaded)
+	}
+
+	if actual, loaded := c.LoadOrStore("key1", 20, 5*time.Second); !loaded || actual != 10 {
+		t.Fatalf("expected to load 10, but got %v, loaded: %v", actual, loaded)
+	}
+
+	if value, loaded := c.LoadAndDelete("key1"); !loaded || value != 10 {
+		t.Fatalf("expected to delete 10, but got %v, loaded: %v", value, loaded)
+	}
+
+	if _, loaded := c.LoadAndDelete("key1"); loaded {
+		t.Fatal("expected item to be missing after delete")
+	}
+
+	c.Freeze()
+
+	// should return value without storing it in a frozen cache.
+	if actual, loaded := c.LoadOrStore("key2", 30, 5*time.Second); loaded || actual != 30 {
+		t.Fatalf("expected 30, but got %v, loaded: %v", actual, loaded)
+	}
+	if _, found := c.Peek("key2"); found {
+		t.Fatal("expected key2 not to be stored in a frozen cache")
+	}
+}
+
+func TestCachePopWithExpiry(t *testing.T) {
+
+	t.Parallel()
+
+	c := New[string, int](1 * time.Second)
+
+	before := time.Now()
+	c.Set("key1", 100, 5*time.Second)
+
+	value, expiry, found := c.PopWithExpiry("key1")
+	if !found || value != 100 {
+		t.Fatalf("expected to pop 100, but got %v, found: %v", value, found)
+	}
+	if expiry.Before(before.Add(5*time.Second)) || expiry.After(time.Now().Add(5*time.Second)) {
+		t.Fatalf("expected expiry about 5s from now, but got %v", expiry)
+	}
+
+	// should be missing.
+	if _, _, found := c.PopWithExpiry("key1"); found {
+		t.Fatal("expected item to be missing after pop")
+	}
+}
+
+func TestCacheCompareAndSwap(t *testing.T) {
+
+	t.Parallel()
+
+	c := New[string, int](1 * time.Second)
+
+	c.Set("key1", 10, 5*time.Second)
+
+	if c.CompareAndSwap("key1", 20, 30, 5*time.Second) {
+		t.Fatal("expected swap with a stale value to fail")
+	}
+	if !c.CompareAndSwap("key1", 10, 30, 5*time.Second) {
+		t.Fatal("expected swap with the current value to succeed")
+	}
+	if c.CompareAndDelete("key1", 10) {
+		t.Fatal("expected delete with a stale value to fail")
+	}
+	if !c.CompareAndDelete("key1", 30) {
+		t.Fatal("expected delete with the current value to succeed")
+	}
+}
+
+func TestCacheCompareAndSwapWithEquals(t *testing.T) {
+
+	t.Parallel()
+
+	c := New(1*time.Second, WithEquals[string, []int](slices.Equal[[]int]))
+
+	c.Set("key1", []int{1, 2}, 5*time.Second)
+
+	if !c.CompareAndSwap("key1", []int{1, 2}, []int{3}, 5*time.Second) {
+		t.Fatal("expected swap with an equal slice to succeed")
+	}
+	if value, _ := c.Get("key1"); !slices.Equal(value, []int{3}) {
+		t.Fatalf("expected [3], but got %v", value)
+	}
+}
+
+func TestCacheCountWhere(t *testing.T) {
+
+	t.Parallel()
+
+	c := New(1*time.Second, WithManualCleanup[string, int]())
+
+	c.Set("key1", 10, 5*time.Second)
+	c.Set("key2", 20, 5*time.Second)
+	c.Set("key3", 30, 5*time.Second)
+	c.Set("key4", 40, 0*time.Second)
+
+	// should count the live matching items only.
+	if n := c.CountWhere(func(key string, value int) bool { return value >= 20 }); n != 2 {
+		t.Fatalf("expected 2 matching items, but got %d", n)
+	}
+}
+
+func TestCacheSetTTLWhere(t *testing.T) {
+
+	t.Parallel()
+
+	c := New[string, int](1 * time.Second)
+
+	c.Set("premium1", 1, 100*time.Millisecond)
+	c.Set("premium2", 2, 100*time.Millisecond)
+	c.Set("basic", 3, 100*time.Millisecond)
+	c.Set("expired", 4, 0*time.Second)
+
+	updated := c.SetTTLWhere(func(key string, value int) bool {
+		return key != "basic"
+	}, 5*time.Second)
+
+	if updated != 2 {
+		t.Fatalf("expected 2 updated items, but got %d", updated)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	_, found := c.GetOrdered([]string{"premium1", "premium2", "basic"})
+	if !found[0] || !found[1] || found[2] {
+		t.Fatalf("expected only premium items to survive, but got %v", found)
+	}
+}
+
+func TestCacheSetWithIdle(t *testing.T) {
+
+	t.Parallel()
+
+	c := New[string, int](1 * time.Second)
+
+	c.SetWithIdle("idle", 1, 5*time.Second, 100*time.Millisecond)
+	c.SetWithIdle("busy", 2, 300*time.Millisecond, 100*time.Millisecond)
+
+	// Reading busy keeps it alive past its idle timeout.
+	for i := 0; i < 4; i++ {
+		time.Sleep(50 * time.Millisecond)
+		if _, found := c.Get("busy"); !found {
+			t.Fatal("expected busy item to stay alive while read")
+		}
+	}
+
+	if _, found := c.Get("idle"); found {
+		t.Fatal("expected idle item to expire after its idle timeout")
+	}
+
+	// should expire at its absolute TTL despite being read.
+	time.Sleep(50 * time.Millisecond)
+	c.Get("busy")
+	time.Sleep(60 * time.Millisecond)
+
+	if _, found := c.Get("busy"); found {
+		t.Fatal("expected busy item to expire at its absolute TTL")
+	}
+}
+
+func TestCacheGetCloner(t *testing.T) {
+
+	t.Parallel()
+
+	c := New(1*time.Second, WithGetCloner[string, []int](slices.Clone[[]int]))
+
+	c.Set("key1", []int{1, 2}, 5*time.Second)
+
+	value, _ := c.Get("key1")
+	value[0] = 100
+
+	if value, _ := c.Get("key1"); value[0] != 1 {
+		t.Fatalf("expected the stored value to be unchanged, but got %v", value)
+	}
+}
+
+func TestCacheSweepHandler(t *testing.T) {
+
+	t.Parallel()
+
+	swept := make(map[string]int)
+
+	c := New(1*time.Second,
+		WithManualCleanup[string, int](),
+		WithSweepHandler(func(key string, value int) {
+			swept[key] = value
+		}),
+	)
+
+	c.Set("key1", 10, 0*time.Second)
+	c.Set("key2", 20, 0*time.Second)
+	c.Set("key3", 30, 5*time.Second)
+
+	// should not reach the handler because it is removed lazily.
+	c.Get("key2")
+
+	c.RunCleanup()
+
+	if len(swept) != 1 || swept["key1"] != 10 {
+		t.Fatalf("expected only key1 to be swept, but got %v", swept)
+	}
+}
+
+func TestCacheKeys(t *testing.T) {
+
+	t.Parallel()
+
+	c := New[string, int](1 * time.Second)
+
+	c.Set("key1", 10, 5*time.Second)
+	c.Set("key2", 20, 5*time.Second)
+	c.Set("key3", 30, 0*time.Second)
+
+	keys := c.Keys()
+	slices.Sort(keys)
+
+	if !slices.Equal(keys, []string{"key1", "key2"}) {
+		t.Fatalf("expected [key1 key2], but got %v", keys)
+	}
+}
+
+func TestCacheExpiredKeys(t *testing.T) {
+
+	t.Parallel()
+
+	c := New(1*time.Second, WithManualCleanup[string, int]())
+
+	c.Set("key1", 10, 5*time.Second)
+	c.Set("key2", 20, 0*time.Second)
+	c.Set("key3", 30, 0*time.Second)
+
+	keys := c.ExpiredKeys()
+	slices.Sort(keys)
+
+	if !slices.Equal(keys, []string{"key2", "key3"}) {
+		t.Fatalf("expected [key2 key3], but got %v", keys)
+	}
+
+	// should leave the expired items in place.
+	if keys := c.ExpiredKeys(); len(keys) != 2 {
+		t.Fatalf("expected 2 expired keys, but got %v", keys)
+	}
+}
+
+func TestCacheKeyComparator(t *testing.T) {
+
+	t.Parallel()
+
+	c := New(1*time.Second, WithKeyComparator[int, string](func(a, b int) int {
+		return cmp.Compare(b, a)
+	}))
+
+	for key := range 10 {
+		c.Set(key, fmt.Sprint(key), 5*time.Second)
+	}
+
+	// should order keys and snapshot entries with the comparator.
+	if keys := c.Keys(); !slices.Equal(keys, []int{9, 8, 7, 6, 5, 4, 3, 2, 1, 0}) {
+		t.Fatalf("expected descending keys, but got %v", keys)
+	}
+
+	s := c.Export()
+	for n, e := range s.Entries {
+		if e.Key != 9-n {
+			t.Fatalf("expected key %d at position %d, but got %v", 9-n, n, e.Key)
+		}
+	}
+
+	if keys := SortedKeys(c); !slices.Equal(keys, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}) {
+		t.Fatalf("expected ascending keys, but got %v", keys)
+	}
+}
+
+func TestCacheSetReporting(t *testing.T) {
+
+	t.Parallel()
+
+	c := New[string, int](1 * time.Second)
+
+	if c.SetReporting("key1", 10, 5*time.Second) {
+		t.Fatal("expected a new item not to be reported as replaced")
+	}
+	if !c.SetReporting("key1", 20, 5*time.Second) {
+		t.Fatal("expected a live item to be reported as replaced")
+	}
+
+	// should report the expired item as absent.
+	c.Set("key2", 30, 0*time.Second)
+	if c.SetReporting("key2", 40, 5*time.Second) {
+		t.Fatal("expected an expired item not to be reported as replaced")
+	}
+}
+
+func TestCacheAdaptiveCleanup(t *testing.T) {
+
+	t.Parallel()
+
+	c := New(1*time.Second,
+		WithManualCleanup[string, int](),
+		WithAdaptiveCleanup[string, int](100*time.Millisecond, 4*time.Second),
+	)
+
+	tests := []struct {
+		interval time.Duration
+		scanned  int
+		expired  int
+		expected time.Duration
+	}{
+		{1 * time.Second, 100, 50, 500 * time.Millisecond},
+		{1 * time.Second, 100, 10, 1 * time.Second},
+		{1 * time.Second, 100, 1, 2 * time.Second},
+		{1 * time.Second, 0, 0, 2 * time.Second},
+		{150 * time.Millisecond, 100, 90, 100 * time.Millisecond},
+		{3 * time.Second, 100, 0, 4 * time.Second},
+	}
+
+	for _, tt := range tests {
+		report := CleanupReport{Scanned: tt.scanned, Expired: tt.expired}
+		if next := c.adaptInterval(tt.interval, report); next != tt.expected {
+			t.Errorf("%v with %d/%d expired: expected %v, but got %v", tt.interval, tt.expired, tt.scanned, tt.expected, next)
+		}
+	}
+
+	zero := New(1*time.Second,
+		WithManualCleanup[string, int](),
+		WithAdaptiveCleanup[string, int](0, 0),
+	)
+
+	// should never halve the interval down to zero.
+	interval := time.Second
+	for range 64 {
+		interval = zero.adaptInterval(interval, CleanupReport{Scanned: 100, Expired: 100})
+	}
+	if interval != minAdaptiveCleanup {
+		t.Fatalf("expected %v, but got %v", minAdaptiveCleanup, interval)
+	}
+}
+
+func TestCacheNonPositiveCleanupInterval(t *testing.T) {
+
+	t.Parallel()
+
+	var passes atomic.Int32
+
+	c := New(0, WithCleanupObserver[string, int](func(CleanupReport) {
+		passes.Add(1)
+	}))
+	defer c.Close()
+
+	c.Set("key1", 10, 0*time.Second)
+	time.Sleep(50 * time.Millisecond)
+
+	// should not start the cleanup goroutine.
+	if n := passes.Load(); n != 0 {
+		t.Fatalf("expected no cleanup pass, but got %d", n)
+	}
+}
+
+func TestCacheCleanupSchedule(t *testing.T) {
+
+	t.Parallel()
+
+	var cycles, passes atomic.Int32
+
+	c := New(10*time.Millisecond,
+		WithCleanupSchedule[string, int](func(now time.Time) time.Duration {
+			// should skip every other pass.
+			if cycles.Add(1)%2 == 0 {
+				return 0
+			}
+			return 10 * time.Millisecond
+		}),
+		WithCleanupObserver[string, int](func(CleanupReport) {
+			passes.Add(1)
+		}),
+	)
+	defer c.Close()
+
+	c.Set("key1", 10, 0*time.Second)
+
+	time.Sleep(200 * time.Millisecond)
+
+	n, p := cycles.Load(), passes.Load()
+	if n < 4 {
+		t.Fatalf("expected the schedule to be consulted every cycle, but got %d cycles", n)
+	}
+	if p < n/2-1 || p > n/2+1 {
+		t.Fatalf("expected about half of %d cycles to run a pass, but got %d", n, p)
+	}
+}
+
+func TestCacheRemoveExpiredN(t *testing.T) {
+
+	t.Parallel()
+
+	c := New[string, int](1 * time.Second)
+
+	c.Set("key1", 10, 0*time.Second)
+	c.Set("key2", 20, 0*time.Second)
+	c.Set("key3", 30, 5*time.Second)
+
+	if removed := c.RemoveExpiredN(); removed != 2 {
+		t.Fatalf("expected 2 removed items, but got %d", removed)
+	}
+	if removed := c.RemoveExpiredN(); removed != 0 {
+		t.Fatalf("expected 0 removed items, but got %d", removed)
+	}
+}
+
+func TestCacheSetWithDeps(t *testing.T) {
+
+	t.Parallel()
+
+	c := New[string, int](1 * time.Second)
+
+	c.Set("parent", 1, 5*time.Second)
+	c.SetWithDeps("child", 2, 5*time.Second, "parent")
+	c.SetWithDeps("grandchild", 3, 5*time.Second, "child")
+	c.Set("unrelated", 4, 5*time.Second)
+
+	c.Remove("parent")
+
+	_, found := c.GetOrdered([]string{"parent", "child", "grandchild", "unrelated"})
+	if found[0] || found[1] || found[2] || !found[3] {
+		t.Fatalf("expected only unrelated to remain, but got %v", found)
+	}
+
+	// should cascade on expiry and survive cycles.
+	c.Set("a", 1, 0*time.Second)
+	c.SetWithDeps("b", 2, 5*time.Second, "a", "c")
+	c.SetWithDeps("c", 3, 5*time.Second, "b")
+
+	c.RemoveExpired()
+
+	if _, found := c.GetOrdered([]string{"a", "b", "c"}); found[0] || found[1] || found[2] {
+		t.Fatalf("expected the whole chain to be removed, but got %v", found)
+	}
+	if len(c.dependents) != 0 || len(c.dependencies) != 0 {
+		t.Fatalf("expected the dependency graph to be empty, but got %v and %v", c.dependents, c.dependencies)
+	}
+}
+
+func TestCacheGetAndAdjustTTL(t *testing.T) {
+
+	t.Parallel()
+
+	c := New[string, int](1 * time.Second)
+
+	c.Set("key1", 10, 5*time.Second)
+	c.Set("key2", 20, 5*time.Second)
+
+	// should shorten the remaining time to live.
+	value, found := c.GetAndAdjustTTL("key1", func(remaining time.Duration, value int) time.Duration {
+		if remaining <= 4*time.Second || remaining > 5*time.Second {
+			t.Errorf("expected about 5s remaining, but got %v", remaining)
+		}
+		return 50 * time.Millisecond
+	})
+	if !found || value != 10 {
+		t.Fatalf("expected 10, but got %v, found: %v", value, found)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if _, found := c.Get("key1"); found {
+		t.Fatal("expected key1 to expire early")
+	}
+
+	// should expire the item right away, still returning it once.
+	value, found = c.GetAndAdjustTTL("key2", func(time.Duration, int) time.Duration { return 0 })
+	if !found || value != 20 {
+		t.Fatalf("expected 20, but got %v, found: %v", value, found)
+	}
+	if _, found := c.Get("key2"); found {
+		t.Fatal("expected key2 to be expired")
+	}
+
+	if _, found := c.GetAndAdjustTTL("missing", func(time.Duration, int) time.Duration { return time.Second }); found {
+		t.Fatal("expected missing key not to be found")
+	}
+}
+
+func TestCacheGetAndMaybeExtend(t *testing.T) {
+
+	t.Parallel()
+
+	c := New[string, int](1 * time.Second)
+
+	c.Set("key1", 10, 200*time.Millisecond)
+	c.Set("key2", 20, 200*time.Millisecond)
+
+	time.Sleep(150 * time.Millisecond)
+
+	// key1 is read just before expiry, so it is extended.
+	if value, found := c.GetAndMaybeExtend("key1", 5*time.Second, 100*time.Millisecond); !found || value != 10 {
+		t.Fatalf("expected 10, but got %v, found: %v", value, found)
+	}
+
+	// key2 has enough time left, so it is not extended.
+	c.GetAndMaybeExtend("key2", 5*time.Second, 10*time.Millisecond)
+
+	time.Sleep(100 * time.Millisecond)
+
+	if _, found := c.Get("key1"); !found {
+		t.Fatal("expected key1 to survive after being extended")
+	}
+	if _, found := c.Get("key2"); found {
+		t.Fatal("expected key2 to expire on its original schedule")
+	}
+}
+
+func TestCacheFreeze(t *testing.T) {
+
+	t.Parallel()
+
+	c := New(1*time.Second, WithManualCleanup[string, int]())
+
+	c.Set("key1", 10, 5*time.Second)
+	c.Set("key2", 20, 0*time.Second)
+
+	c.Freeze()
+
+	if !c.IsFrozen() {
+		t.Fatal("expected cache to be frozen")
+	}
+
+	c.Set("key1", 30, 5*time.Second)
+	c.Remove("key1")
+	if err := c.Add("key3", 30, 5*time.Second); !errors.Is(err, ErrFrozen) {
+		t.Fatalf("expected ErrFrozen, but got %v", err)
+	}
+
+	if value, found := c.Get("key1"); !found || value != 10 {
+		t.Fatalf("expected 10 to be readable while frozen, but got %v, found: %v", value, found)
+	}
+
+	// should leave the expired item in place.
+	c.Get("key2")
+	c.RunCleanup()
+	if len(c.items) != 2 {
+		t.Fatalf("expected 2 stored items while frozen, but got %d", len(c.items))
+	}
+
+	c.Unfreeze()
+	c.RunCleanup()
+
+	if len(c.items) != 1 {
+		t.Fatalf("expected 1 stored item after unfreezing, but got %d", len(c.items))
+	}
+	if err := c.Add("key3", 30, 5*time.Second); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+}
+
+func TestCachePeek(t *testing.T) {
+
+	t.Parallel()
+
+	c := New(1*time.Second, WithManualCleanup[string, int]())
+
+	c.Set("key1", 10, 5*time.Second)
+	c.Set("key2", 20, 50*time.Millisecond)
+
+	if value, found := c.Peek("key1"); !found || value != 10 {
+		t.Fatalf("expected 10, but got %v, found: %v", value, found)
+	}
+
+	// should not count a hit.
+	if hits := c.items["key1"].hits; hits != 0 {
+		t.Fatalf("expected 0 hits, but got %d", hits)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	// should report the expired item as missing and leave it in place.
+	if _, found := c.Peek("key2"); found {
+		t.Fatal("expected key2 to be expired")
+	}
+	if len(c.items) != 2 {
+		t.Fatalf("expected 2 stored items, but got %d", len(c.items))
+	}
+	if n := c.Len(); n != 1 {
+		t.Fatalf("expected 1 live item, but got %d", n)
+	}
+}
+
+func TestCacheTTLGranularity(t *testing.T) {
+
+	t.Parallel()
+
+	c := New(1*time.Second,
+		WithManualCleanup[string, int](),
+		WithTTLGranularity[string, int](time.Second),
+	)
+
+	c.Set("key1", 10, 100*time.Millisecond)
+	c.Set("key2", 20, 700*time.Millisecond)
+	c.SetWithIdle("key3", 30, 300*time.Millisecond, time.Minute)
+
+	// should round every expiry up to a whole second.
+	for key, i := range c.items {
+		if !i.expiry.Equal(i.expiry.Truncate(time.Second)) {
+			t.Fatalf("expected expiry of %s to be rounded to a second, but got %v", key, i.expiry)
+		}
+		if i.expiry.Before(time.Now()) {
+			t.Fatalf("expected %s to expire in the future, but got %v", key, i.expiry)
+		}
+	}
+
+	// should not round without a granularity.
+	plain := New(1*time.Second, WithManualCleanup[string, int]())
+	plain.Set("key1", 10, 100*time.Millisecond)
+
+	if i := plain.items["key1"]; i.expiry.Sub(time.Now()) > 100*time.Millisecond {
+		t.Fatalf("expected unrounded expiry, but got %v", i.expiry)
+	}
+}
+
+func TestCacheEmptyStateCallback(t *testing.T) {
+
+	t.Parallel()
+
+	var transitions []bool
+
+	c := New(1*time.Second,
+		WithManualCleanup[string, int](),
+		WithCapacity[string, int](1),
+		WithEmptyStateCallback[string, int](func(empty bool) {
+			transitions = append(transitions, empty)
+		}),
+	)
+
+	c.Set("key1", 10, 5*time.Second)
+	c.Set("key1", 11, 5*time.Second)
+
+	// should not report the eviction that makes room for key2.
+	c.Set("key2", 20, 5*time.Second)
+
+	c.Remove("key2")
+	c.Remove("key2")
+
+	c.Set("key3", 30, 50*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	c.RunCleanup()
+
+	c.Set("key4", 40, 5*time.Second)
+	c.Close()
+
+	expected := []bool{false, true, false, true, false, true}
+	if !slices.Equal(transitions, expected) {
+		t.Fatalf("expected transitions %v, but got %v", expected, transitions)
+	}
+}
+
+func TestCacheMove(t *testing.T) {
+
+	t.Parallel()
+
+	l1 := New[string, int](1 * time.Second)
+	l2 := New[string, int](1 * time.Second)
+
+	l1.Set("key1", 10, 5*time.Second)
+	expiry := l1.items["key1"].expiry
+
+	if !Move(l1, l2, "key1", 0) {
+		t.Fatal("expected key1 to be moved")
+	}
+	if _, found := l1.Get("key1"); found {
+		t.Fatal("expected key1 to be gone from the source")
+	}
+	if value, found := l2.Get("key1"); !found || value != 10 {
+		t.Fatalf("expected 10, but got %v, found: %v", value, found)
+	}
+
+	// should keep the expiry without a ttl.
+	if got := l2.items["key1"].expiry; !got.Equal(expiry) {
+		t.Fatalf("expected expiry %v, but got %v", expiry, got)
+	}
+
+	// should reset the expiry with a ttl.
+	if !Move(l2, l1, "key1", time.Minute) {
+		t.Fatal("expected key1 to be moved back")
+	}
+	if got := l1.items["key1"].ttl; got != time.Minute {
+		t.Fatalf("expected a TTL of 1m, but got %v", got)
+	}
+
+	if Move(l2, l1, "missing", 0) {
+		t.Fatal("expected a missing key not to be moved")
+	}
+
+	l2.Close()
+	if Move(l1, l2, "key1", 0) {
+		t.Fatal("expected no move into a closed cache")
+	}
+	if _, found := l1.Get("key1"); !found {
+		t.Fatal("expected key1 to stay in the source")
+	}
+
+	// should not deadlock when moving in opposite directions.
+	a := New[int, int](1 * time.Second)
+	b := New[int, int](1 * time.Second)
+
+	var wg sync.WaitGroup
+	for n := range 100 {
+		a.Set(n, n, 5*time.Second)
+		b.Set(-n-1, n, 5*time.Second)
+
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			Move(a, b, n, 0)
+		}()
+		go func() {
+			defer wg.Done()
+			Move(b, a, -n-1, 0)
+		}()
+	}
+	wg.Wait()
+
+	if a.Len() != 100 || b.Len() != 100 {
+		t.Fatalf("expected 100 items in each cache, but got %d and %d", a.Len(), b.Len())
+	}
+}
+
+// TestNow replaces the package clock, so it doesn't run in parallel with the
+// tests using real time.
+func TestNow(t *testing.T) {
+
+	// Now is global and read by the goroutines of caches left running by
+	// the other tests, so the test replaces it in a process of its own.
+	if os.Getenv("GO_CACHE_TEST_NOW") == "" {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestNow$")
+		cmd.Env = append(os.Environ(), "GO_CACHE_TEST_NOW=1")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("expected the subprocess to pass, but got %v, found: %s", err, out)
+		}
+		return
+	}
+
+	now := time.Now()
+	Now = func() time.Time { return now }
+	defer func() { Now = time.Now }()
+
+	c := New(1*time.Second, WithManualCleanup[string, int]())
+
+	c.Set("key1", 10, time.Minute)
+
+	now = now.Add(59 * time.Second)
+	if _, found := c.Get("key1"); !found {
+		t.Fatal("expected key1 to be live before its expiry")
+	}
+
+	// should expire once the clock passes the expiry.
+	now = now.Add(2 * time.Second)
+	if _, found := c.Get("key1"); found {
+		t.Fatal("expected key1 to be expired")
+	}
+}
+
+func TestCacheMustGet(t *testing.T) {
+
+	t.Parallel()
+
+	c := New[string, int](1 * time.Second)
+
+	c.Set("key1", 0, 5*time.Second)
+
+	// should return a stored zero value.
+	if value := c.MustGet("key1"); value != 0 {
+		t.Fatalf("expected 0, but got %v", value)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("expected MustGet to panic on a miss")
+		}
+	}()
+
+	c.MustGet("missing")
+}
+
+func BenchmarkCacheWarmup(b *testing.B) {
+
+	const n = 100_000
+
+	warmup := func(b *testing.B, opts ...Option[int, int]) {
+		b.ReportAllocs()
+		for range b.N {
+			c := New(1*time.Second, append(opts, WithManualCleanup[int, int]())...)
+			for key := range n {
+				c.Set(key, key, time.Minute)
+			}
+		}
+	}
+
+	b.Run("default", func(b *testing.B) {
+		warmup(b)
+	})
+	b.Run("preallocated", func(b *testing.B) {
+		warmup(b, WithInitialCapacity[int, int](n))
+	})
+}
+
+func TestCacheInitialCapacity(t *testing.T) {
+
+	t.Parallel()
+
+	c := New(1*time.Second, WithInitialCapacity[string, int](1000))
+
+	for n := range 2000 {
+		c.Set(fmt.Sprint(n), n, 5*time.Second)
+	}
+
+	// should not bound the number of items.
+	if n := c.Len(); n != 2000 {
+		t.Fatalf("expected 2000 items, but got %d", n)
+	}
+}
+
+func TestCacheGetAndReset(t *testing.T) {
+
+	t.Parallel()
+
+	c := New[string, int](1 * time.Second)
+
+	var wg sync.WaitGroup
+	for range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Update("hits", 5*time.Second, func(old int, found bool) (int, bool) {
+				return old + 1, true
+			})
+		}()
+	}
+
+	// should not lose increments made between flushes.
+	total := 0
+	for range 10 {
+		old, _ := c.GetAndReset("hits", 0, 5*time.Second)
+		total += old
+	}
+	wg.Wait()
+
+	old, found := c.GetAndReset("hits", 0, 5*time.Second)
+	if !found {
+		t.Fatal("expected hits to be found")
+	}
+	if total += old; total != 100 {
+		t.Fatalf("expected 100 flushed hits, but got %d", total)
+	}
+
+	if value, found := c.Get("hits"); !found || value != 0 {
+		t.Fatalf("expected 0, but got %v, found: %v", value, found)
+	}
+}
+
+func TestCacheTryGet(t *testing.T) {
+
+	t.Parallel()
+
+	c := New[string, int](1 * time.Second)
+
+	c.Set("key1", 10, 5*time.Second)
+
+	if value, found, locked := c.TryGet("key1"); !locked || !found || value != 10 {
+		t.Fatalf("expected 10, but got %v, found: %v, locked: %v", value, found, locked)
+	}
+	if _, found, locked := c.TryGet("missing"); !locked || found {
+		t.Fatalf("expected a miss, but got found: %v, locked: %v", found, locked)
+	}
+
+	// should give up right away while a writer holds the lock.
+	c.mu.Lock()
+	_, found, locked := c.TryGet("key1")
+	c.mu.Unlock()
+
+	if locked || found {
+		t.Fatalf("expected the lock not to be acquired, but got found: %v, locked: %v", found, locked)
+	}
+}
+
+func TestCacheInspect(t *testing.T) {
+
+	t.Parallel()
+
+	c := New[string, int](1 * time.Second)
+
+	before := time.Now()
+	c.Set("key1", 10, 5*time.Second)
+	c.Get("key1")
+
+	info, found := c.Inspect("key1")
+	if !found || info.Value != 10 || info.TTL != 5*time.Second || info.Hits != 1 {
+		t.Fatalf("expected key1 with 1 hit, but got %+v, found: %v", info, found)
+	}
+	if info.Created.Before(before) || info.Expiry.Sub(info.Created) != 5*time.Second {
+		t.Fatalf("expected creation time after %v, but got %+v", before, info)
+	}
+
+	// should keep the creation time when only the TTL changes.
+	time.Sleep(10 * time.Millisecond)
+	c.SetTTLWhere(func(key string, value int) bool { return true }, time.Minute)
+
+	if updated, _ := c.Inspect("key1"); !updated.Created.Equal(info.Created) || updated.Age() < 10*time.Millisecond {
+		t.Fatalf("expected creation time %v, but got %v", info.Created, updated.Created)
+	}
+
+	// should reset the creation time when the value is set again.
+	c.Set("key1", 20, 5*time.Second)
+
+	if updated, _ := c.Inspect("key1"); !updated.Created.After(info.Created) {
+		t.Fatalf("expected a creation time after %v, but got %v", info.Created, updated.Created)
+	}
+
+	if _, found := c.Inspect("missing"); found {
+		t.Fatal("expected missing key not to be found")
+	}
+	if updated, _ := c.Inspect("key1"); updated.Size != 0 {
+		t.Fatalf("expected no size without a size function, but got %d", updated.Size)
+	}
+
+	// should report the estimated size of the item.
+	sized := New(1*time.Second, WithSizeFunc[string, string](func(key, value string) int64 {
+		return int64(len(key) + len(value))
+	}))
+	sized.Set("key1", "large value", 5*time.Second)
+
+	if info, _ := sized.Inspect("key1"); info.Size != 15 {
+		t.Fatalf("expected a size of 15, but got %d", info.Size)
+	}
+}
+
+func TestCacheRemoveExpiredAsync(t *testing.T) {
+
+	t.Parallel()
+
+	c := New(1*time.Second, WithManualCleanup[int, int]())
+
+	for key := range 5000 {
+		ttl := 5 * time.Second
+		if key%2 == 0 {
+			ttl = 0
+		}
+		c.Set(key, key, ttl)
+	}
+
+	// should not start a second pass while one is running.
+	c.mu.Lock()
+	started, again := c.RemoveExpiredAsync(), c.RemoveExpiredAsync()
+	c.mu.Unlock()
+
+	if !started || again {
+		t.Fatalf("expected exactly one pass to start, but got %v and %v", started, again)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c.mu.RLock()
+		n := len(c.items)
+		c.mu.RUnlock()
+
+		if n == 2500 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 2500 items to remain, but got %d", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCacheSetSticky(t *testing.T) {
+
+	t.Parallel()
+
+	c := New(1*time.Second, WithCapacity[string, int](2))
+
+	c.SetSticky("boot", 1, 5*time.Second)
+	c.Set("key1", 10, 5*time.Second)
+	c.Set("key2", 20, 5*time.Second)
+
+	// should evict regular items only.
+	if _, found := c.Get("boot"); !found {
+		t.Fatal("expected the sticky item to survive eviction")
+	}
+	if n := c.Len(); n != 2 {
+		t.Fatalf("expected 2 items, but got %d", n)
+	}
+
+	c.Clear()
+
+	if keys := c.Keys(); !slices.Equal(keys, []string{"boot"}) {
+		t.Fatalf("expected [boot], but got %v", keys)
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	c.ClearAll()
+
+	if n := c.Len(); n != 0 {
+		t.Fatalf("expected 0 items, but got %d", n)
+	}
+
+	// should still expire by TTL.
+	c.SetSticky("boot", 1, 0*time.Second)
+	if _, found := c.Get("boot"); found {
+		t.Fatal("expected the sticky item to expire")
+	}
+}
+
+func TestCacheOverflowPolicy(t *testing.T) {
+
+	t.Parallel()
+
+	fill := func(policy OverflowPolicy) *Cache[string, int] {
+		c := New(1*time.Second, WithCapacity[string, int](2), WithOverflowPolicy[string, int](policy))
+		c.SetSticky("key1", 10, 5*time.Second)
+		c.SetSticky("key2", 20, 3*time.Second)
+		return c
+	}
+
+	// should grow beyond the capacity by default.
+	grow := fill(OverflowGrow)
+	if err := grow.TrySet("key3", 30, 5*time.Second); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if n := grow.Len(); n != 3 {
+		t.Fatalf("expected 3 items, but got %d", n)
+	}
+
+	// should reject the write, with an error from TrySet only.
+	reject := fill(OverflowReject)
+	if err := reject.TrySet("key3", 30, 5*time.Second); !errors.Is(err, ErrFull) {
+		t.Fatalf("expected ErrFull, but got %v", err)
+	}
+	reject.Set("key3", 30, 5*time.Second)
+	if _, found := reject.Get("key3"); found {
+		t.Fatal("expected the write to be dropped")
+	}
+	if err := reject.TrySet("key1", 11, 5*time.Second); err != nil {
+		t.Fatalf("expected an existing key to be updated, but got %v", err)
+	}
+
+	// should report the dropped write from every method that can.
+	reject = fill(OverflowReject)
+	if err := reject.Add("key3", 30, 5*time.Second); !errors.Is(err, ErrFull) {
+		t.Fatalf("expected ErrFull, but got %v", err)
+	}
+	if reject.Update("key3", 5*time.Second, func(int, bool) (int, bool) { return 30, true }) {
+		t.Fatal("expected Update to report the dropped write")
+	}
+	if total := IncrementOrSet(reject, "key3", 1, 30, 5*time.Second); total != 0 {
+		t.Fatalf("expected 0, but got %d", total)
+	}
+	if version := reject.SetVersioned("key3", 30, 5*time.Second); version != 0 {
+		t.Fatalf("expected version 0, but got %d", version)
+	}
+	reject.SetWithDeps("key3", 30, 5*time.Second, "key1")
+	if err := reject.Validate(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	// should leave the item in the source when the destination is full.
+	src := New[string, int](1 * time.Second)
+	src.Set("key3", 30, 5*time.Second)
+
+	if Move(src, reject, "key3", 0) {
+		t.Fatal("expected the move into a full cache to fail")
+	}
+	if value, found := src.Get("key3"); !found || value != 30 {
+		t.Fatalf("expected key3 to stay in the source, but got %v, found: %v", value, found)
+	}
+
+	// should evict the sticky item expiring first.
+	evict := fill(OverflowEvictSticky)
+	if err := evict.TrySet("key3", 30, 5*time.Second); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if keys := SortedKeys(evict); !slices.Equal(keys, []string{"key1", "key3"}) {
+		t.Fatalf("expected [key1 key3], but got %v", keys)
+	}
+}
+
+// TestCacheCompact measures the heap, so it doesn't run in parallel with
+// other tests.
+func TestCacheCompact(t *testing.T) {
+
+	heap := func() uint64 {
+		var m runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&m)
+		return m.HeapAlloc
+	}
+
+	c := New(1*time.Second, WithManualCleanup[int, [64]byte]())
+
+	for key := range 100_000 {
+		c.Set(key, [64]byte{}, 5*time.Second)
+	}
+	for key := range 99_000 {
+		c.Remove(key)
+	}
+	c.Set(-1, [64]byte{}, 0*time.Second)
+
+	before := heap()
+	c.Compact()
+	after := heap()
+
+	// should release at least the key and value pointer held by the slot of
+	// every removed item, whatever the size of the rest of the heap, and
+	// drop the expired item.
+	const removed = 99_000 * 16
+	if after > before || before-after < removed {
+		t.Fatalf("expected at least %d bytes freed, but got %d -> %d", removed, before, after)
+	}
+	if n := len(c.items); n != 1000 {
+		t.Fatalf("expected 1000 items, but got %d", n)
+	}
+
+	runtime.KeepAlive(c)
+}
+
+func BenchmarkCacheParallelDistinctKeys(b *testing.B) {
+
+	c := New(1*time.Second, WithManualCleanup[int, int]())
+
+	const keys = 1 << 16
+	for key := range keys {
+		c.Set(key, key, time.Minute)
+	}
+
+	var worker atomic.Int64
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		key := int(worker.Add(1)) * 7919
+		for pb.Next() {
+			key = (key + 1) % keys
+			if key%4 == 0 {
+				c.Set(key, key, time.Minute)
+			} else {
+				c.Get(key)
+			}
+		}
+	})
+}
+
+func TestCacheHotSliding(t *testing.T) {
+
+	t.Parallel()
+
+	c := New(1*time.Second,
+		WithManualCleanup[string, int](),
+		WithHotSliding[string, int](100*time.Millisecond, 3),
+	)
+
+	c.Set("hot", 1, 200*time.Millisecond)
+	c.Set("cold", 2, 200*time.Millisecond)
+
+	// should keep extending the hot key, read every 20ms.
+	for n := range 25 {
+		if _, found := c.Get("hot"); !found {
+			t.Fatalf("expected the hot key to be alive after %d reads", n)
+		}
+		if n%10 == 0 {
+			c.Get("cold")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if _, found := c.Get("cold"); found {
+		t.Fatal("expected the cold key to expire on schedule")
+	}
+	if _, found := c.Get("hot"); !found {
+		t.Fatal("expected the hot key to survive")
+	}
+}
+
+func TestCacheMaxRenewals(t *testing.T) {
+
+	t.Parallel()
+
+	c := New(1*time.Second,
+		WithManualCleanup[string, int](),
+		WithMaxRenewals[string, int](3),
+	)
+
+	c.SetWithIdle("key1", 10, 5*time.Second, 100*time.Millisecond)
+
+	// should restart the idle timeout for the first 3 reads only, so the item
+	// expires 100ms after the third read although it keeps being read.
+	for n := range 15 {
+		_, found := c.Get("key1")
+		if n < 5 && !found {
+			t.Fatalf("expected the item to be alive after %d reads", n)
+		}
+		if n >= 10 && found {
+			t.Fatalf("expected the item to expire despite %d reads", n)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	c.Set("key2", 20, 1*time.Minute)
+
+	if _, found := c.GetAndMaybeExtend("key2", 2*time.Minute, time.Hour); !found {
+		t.Fatal("expected key2 to be found")
+	}
+	for range 3 {
+		c.GetAndMaybeExtend("key2", 2*time.Minute, time.Hour)
+	}
+	before, _ := c.Inspect("key2")
+	c.GetAndMaybeExtend("key2", 3*time.Minute, time.Hour)
+
+	// should stop extending once the budget is exhausted.
+	if after, _ := c.Inspect("key2"); !after.Expiry.Equal(before.Expiry) {
+		t.Fatalf("expected expiry %v, but got %v", before.Expiry, after.Expiry)
+	}
+
+	c.SetWithIdle("key3", 30, 1*time.Minute, time.Hour)
+
+	c.GetAndMaybeExtend("key3", 2*time.Minute, time.Hour)
+	before, _ = c.Inspect("key3")
+	c.GetAndMaybeExtend("key3", 3*time.Minute, time.Hour)
+
+	// should use a single renewal per read of an idle item.
+	if after, _ := c.Inspect("key3"); !after.Expiry.After(before.Expiry) {
+		t.Fatalf("expected an expiry after %v, but got %v", before.Expiry, after.Expiry)
+	}
+}
+
+func TestCacheExpiryFunc(t *testing.T) {
+
+	t.Parallel()
+
+	var version atomic.Int64
+	type config struct {
+		version int64
+		value   string
+	}
+
+	c := New(1*time.Second,
+		WithManualCleanup[string, config](),
+		WithExpiryFunc(func(key string, value config, setAt time.Time) bool {
+			return value.version != version.Load()
+		}),
+	)
+
+	c.Set("key1", config{0, "a"}, 5*time.Second)
+	c.Set("key2", config{0, "b"}, 5*time.Second)
+
+	if _, found := c.Get("key1"); !found {
+		t.Fatal("expected key1 to be live")
+	}
+
+	// should expire every item derived from the old version.
+	version.Store(1)
+
+	if _, found := c.Get("key1"); found {
+		t.Fatal("expected key1 to be expired")
+	}
+	if keys := c.Keys(); len(keys) != 0 {
+		t.Fatalf("expected no live keys, but got %v", keys)
+	}
+
+	c.RunCleanup()
+	if n := len(c.items); n != 0 {
+		t.Fatalf("expected the cleanup to remove every item, but got %d", n)
+	}
+}
+
+func TestCacheGetOrSetWithStatus(t *testing.T) {
+
+	t.Parallel()
+
+	c := New[string, int](1 * time.Second)
+
+	var calls, computed atomic.Int32
+	var wg sync.WaitGroup
+
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, fresh := c.GetOrSetWithStatus("key1", 5*time.Second, func() int {
+				calls.Add(1)
+				return 10
+			})
+			if value != 10 {
+				t.Errorf("expected 10, but got %d", value)
+			}
+			if fresh {
+				computed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// should call f once and report it to that caller only.
+	if calls.Load() != 1 || computed.Load() != 1 {
+		t.Fatalf("expected 1 call reported once, but got %d calls reported %d times", calls.Load(), computed.Load())
+	}
+
+	c.Set("key2", 20, 0*time.Second)
+
+	// should recompute an expired item.
+	if value, fresh := c.GetOrSetWithStatus("key2", 5*time.Second, func() int { return 21 }); value != 21 || !fresh {
+		t.Fatalf("expected 21 computed, but got %d, computed: %v", value, fresh)
+	}
+}
+
+func TestCacheGetOrSetMany(t *testing.T) {
+
+	t.Parallel()
+
+	c := New[string, int](1 * time.Second)
+
+	c.Set("key1", 10, 5*time.Second)
+	c.Set("key2", 20, 0*time.Second)
+
+	var computed []string
+	values := c.GetOrSetMany([]string{"key1", "key2", "key3", "key3"}, 5*time.Second, func(key string) int {
+		computed = append(computed, key)
+		return len(computed) * 100
+	})
+
+	// should compute only the missing and expired keys, once each.
+	slices.Sort(computed)
+	if !slices.Equal(computed, []string{"key2", "key3"}) {
+		t.Fatalf("expected key2 and key3 to be computed, but got %v", computed)
+	}
+	if len(values) != 3 || values["key1"] != 10 {
+		t.Fatalf("expected 3 values with key1 at 10, but got %v", values)
+	}
+
+	for _, key := range []string{"key2", "key3"} {
+		if value, found := c.Get(key); !found || value != values[key] {
+			t.Fatalf("expected %s to be stored as %v, but got %v, found: %v", key, values[key], value, found)
+		}
+	}
+}
+
+func TestCacheAutoCompact(t *testing.T) {
+
+	t.Parallel()
+
+	var reports []CleanupReport
+
+	c := New(1*time.Second,
+		WithManualCleanup[int, int](),
+		WithAutoCompact[int, int](0.5),
+		WithCleanupObserver[int, int](func(report CleanupReport) {
+			reports = append(reports, report)
+		}),
+	)
+
+	for key := range 100 {
+		c.Set(key, key, 5*time.Second)
+	}
+
+	// should not compact while the cache is above the ratio.
+	for key := range 40 {
+		c.Remove(key)
+	}
+	c.RunCleanup()
+
+	for key := 40; key < 80; key++ {
+		c.Remove(key)
+	}
+	c.RunCleanup()
+
+	if len(reports) != 2 || reports[0].Compacted || !reports[1].Compacted {
+		t.Fatalf("expected only the second pass to compact, but got %+v", reports)
+	}
+	if c.peak != 20 || c.Len() != 20 {
+		t.Fatalf("expected 20 items and a peak of 20, but got %d and %d", c.Len(), c.peak)
+	}
+}
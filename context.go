@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// lockContext acquires the write lock, giving up with the context's error if
+// ctx is done first. Without WithContextLocking, it only checks ctx before
+// blocking on the lock like the plain methods do.
+func (c *Cache[K, V]) lockContext(ctx context.Context) error {
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if c.lockPoll <= 0 {
+		c.mu.Lock()
+		return nil
+	}
+
+	if c.mu.TryLock() {
+		return nil
+	}
+
+	timer := time.NewTimer(c.lockPoll)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		if c.mu.TryLock() {
+			return nil
+		}
+		timer.Reset(c.lockPoll)
+	}
+}
+
+// GetWithContext retrieves the value stored under key like Get, but returns
+// the context's error if ctx is done before the lock is acquired, and passes
+// ctx on to the loader like Load, reporting its error. With
+// WithServeStaleOnError, a loader error is wrapped in ErrStale and returned
+// along with the expired value. See WithContextLocking.
+func (c *Cache[K, V]) GetWithContext(ctx context.Context, key K) (V, bool, error) {
+
+	value, source, err := c.read(ctx, key, true)
+	return value, source != SourceMiss, err
+}
+
+// SetWithContext inserts an item like Set, but returns the context's error
+// if ctx is done before the lock is acquired. It also reports ErrClosed or
+// ErrFrozen according to the configured policies.
+func (c *Cache[K, V]) SetWithContext(ctx context.Context, key K, data V, ttl time.Duration) error {
+
+	if err := c.lockContext(ctx); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+
+	if blocked, err := c.writeErr(); blocked {
+		return err
+	}
+
+	c.set(key, data, ttl)
+	return nil
+}
+
+// RemoveWithContext removes the item stored under key like Remove, but
+// returns the context's error if ctx is done before the lock is acquired. It
+// also reports ErrClosed or ErrFrozen according to the configured policies.
+func (c *Cache[K, V]) RemoveWithContext(ctx context.Context, key K) error {
+
+	if err := c.lockContext(ctx); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+
+	if blocked, err := c.writeErr(); blocked {
+		return err
+	}
+
+	c.erase(key)
+	return nil
+}
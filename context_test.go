@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCacheContextLocking(t *testing.T) {
+
+	t.Parallel()
+
+	c := New(1*time.Second, WithContextLocking[string, int](time.Millisecond))
+
+	if err := c.SetWithContext(context.Background(), "key1", 10, 5*time.Second); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if value, found, err := c.GetWithContext(context.Background(), "key1"); err != nil || !found || value != 10 {
+		t.Fatalf("expected 10, but got %v, found: %v, err: %v", value, found, err)
+	}
+
+	// should give up while another goroutine holds the lock.
+	c.mu.Lock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, _, err := c.GetWithContext(ctx, "key1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, but got %v", err)
+	}
+	if err := c.RemoveWithContext(ctx, "key1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, but got %v", err)
+	}
+
+	// should acquire the lock once it is released.
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		c.mu.Unlock()
+	}()
+
+	if err := c.RemoveWithContext(context.Background(), "key1"); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if _, found := c.Get("key1"); found {
+		t.Fatal("expected key1 to be removed")
+	}
+
+	c.Close()
+
+	if err := c.SetWithContext(context.Background(), "key1", 10, 5*time.Second); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed, but got %v", err)
+	}
+}
@@ -0,0 +1,233 @@
+package cache
+
+import "sync"
+
+// EventType identifies what happened to an item.
+type EventType int
+
+const (
+	// EventSet reports that an item was stored or updated.
+	EventSet EventType = iota
+
+	// EventRemove reports that an item was deleted by the caller.
+	EventRemove
+
+	// EventExpire reports that an expired item was removed, either by a
+	// cleanup pass or lazily by an operation that found it expired.
+	EventExpire
+
+	// EventEvict reports that an item was evicted to respect the capacity.
+	EventEvict
+
+	// EventBatchExpire reports all the expired items removed by a cleanup
+	// pass, as configured with WithBatchedExpiry. Their keys are listed in
+	// Keys, while Key and Value are left unset.
+	EventBatchExpire
+)
+
+// Event describes a change to a single item of a cache, or to several of them
+// for EventBatchExpire.
+type Event[K comparable, V any] struct {
+	Type  EventType
+	Cache string
+	Key   K
+	Value V
+	Keys  []K
+}
+
+// DropPolicy determines which event is discarded when the events channel
+// is full.
+type DropPolicy int
+
+const (
+	// DropNewest discards the event being published. This is the default.
+	DropNewest DropPolicy = iota
+
+	// DropOldest discards the oldest buffered event to make room for the
+	// one being published.
+	DropOldest
+)
+
+// WithEvents enables the channel returned by Events, buffered to hold size
+// events. Publishing never blocks: when the buffer is full, an event is
+// discarded according to policy and counted in Stats.DroppedEvents. Clear
+// and Close don't publish events on the channel for the items they remove.
+func WithEvents[K comparable, V any](size int, policy DropPolicy) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.events = make(chan Event[K, V], size)
+		c.dropPolicy = policy
+	}
+}
+
+// WithBatchedExpiry makes each cleanup pass publish a single
+// EventBatchExpire event listing the keys of the items it removed, instead
+// of one EventExpire event per item, so a mass expiry doesn't flood the
+// events channel. Passes removing nothing publish no event. Items found
+// expired and removed by other operations still produce EventExpire, and
+// subscribers of a key registered with Subscribe get EventExpire either way.
+func WithBatchedExpiry[K comparable, V any]() Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.batchExpiry = true
+	}
+}
+
+// Events returns the channel receiving item events, or nil if the cache was
+// created without WithEvents. The channel is closed by Close.
+func (c *Cache[K, V]) Events() <-chan Event[K, V] {
+	return c.events
+}
+
+// emit publishes an event without blocking. It must be called with the
+// write lock held, which serializes publishers so that making room for a
+// new event under DropOldest can't race with another publisher.
+func (c *Cache[K, V]) emit(typ EventType, key K, value V) {
+
+	if c.closed || (c.events == nil && c.subscribers == nil) {
+		return
+	}
+
+	e := Event[K, V]{Type: typ, Cache: c.name, Key: key, Value: value}
+
+	for sub := range c.subscribers[key] {
+		sub.push(e)
+	}
+
+	// A batching sweep publishes its expirations itself once it is done.
+	if c.events == nil || (typ == EventExpire && c.sweeping) {
+		return
+	}
+
+	c.publish(e)
+}
+
+// publish sends e on the events channel, discarding an event according to
+// the drop policy if the channel is full. It must be called with the write
+// lock held.
+func (c *Cache[K, V]) publish(e Event[K, V]) {
+
+	select {
+	case c.events <- e:
+		return
+	default:
+	}
+
+	if c.dropPolicy == DropOldest {
+		select {
+		case <-c.events:
+		default:
+		}
+
+		select {
+		case c.events <- e:
+		default:
+		}
+	}
+
+	c.counters.droppedEvents.Add(1)
+}
+
+// subscription delivers the events of a single key to a subscriber. Events
+// are queued by the publisher under the cache lock and sent on ch by a
+// dedicated goroutine, so a slow subscriber never blocks the cache.
+type subscription[K comparable, V any] struct {
+	mu      sync.Mutex
+	pending []Event[K, V]
+
+	ch   chan Event[K, V]
+	wake chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// Subscribe returns a channel receiving the events of key, in order, until
+// cancel is called or the cache is closed, at which point the channel is
+// closed. Events are queued without bound for a subscriber that doesn't
+// keep up, so a subscriber that stops reading must call cancel. A key can
+// have any number of subscribers. Unlike the channel returned by Events,
+// subscribers receive EventRemove when Clear or ClearAll removes their key.
+func (c *Cache[K, V]) Subscribe(key K) (events <-chan Event[K, V], cancel func()) {
+
+	sub := &subscription[K, V]{
+		ch:   make(chan Event[K, V]),
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		close(sub.ch)
+		return sub.ch, func() {}
+	}
+
+	if c.subscribers == nil {
+		c.subscribers = make(map[K]map[*subscription[K, V]]struct{})
+	}
+	if c.subscribers[key] == nil {
+		c.subscribers[key] = make(map[*subscription[K, V]]struct{})
+	}
+	c.subscribers[key][sub] = struct{}{}
+
+	go sub.run()
+
+	cancel = func() {
+		c.mu.Lock()
+		delete(c.subscribers[key], sub)
+		if len(c.subscribers[key]) == 0 {
+			delete(c.subscribers, key)
+		}
+		c.mu.Unlock()
+
+		sub.stop()
+	}
+
+	return sub.ch, cancel
+}
+
+// push queues e for delivery.
+func (s *subscription[K, V]) push(e Event[K, V]) {
+
+	s.mu.Lock()
+	s.pending = append(s.pending, e)
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// stop ends the delivery and closes the channel.
+func (s *subscription[K, V]) stop() {
+	s.once.Do(func() { close(s.done) })
+}
+
+// run delivers the queued events until the subscription is stopped.
+func (s *subscription[K, V]) run() {
+
+	defer close(s.ch)
+
+	for {
+		s.mu.Lock()
+		batch := s.pending
+		s.pending = nil
+		s.mu.Unlock()
+
+		for _, e := range batch {
+			select {
+			case s.ch <- e:
+			case <-s.done:
+				return
+			}
+		}
+
+		if len(batch) == 0 {
+			select {
+			case <-s.wake:
+			case <-s.done:
+				return
+			}
+		}
+	}
+}
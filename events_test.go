@@ -0,0 +1,172 @@
+package cache
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCacheEvents(t *testing.T) {
+
+	t.Parallel()
+
+	c := New(1*time.Second,
+		WithName[string, int]("events"),
+		WithManualCleanup[string, int](),
+		WithEvents[string, int](10, DropNewest),
+	)
+
+	c.Set("key1", 10, 5*time.Second)
+	c.Set("key2", 20, 0*time.Second)
+	c.Remove("key1")
+	c.RunCleanup()
+
+	expected := []Event[string, int]{
+		{Type: EventSet, Cache: "events", Key: "key1", Value: 10},
+		{Type: EventSet, Cache: "events", Key: "key2", Value: 20},
+		{Type: EventRemove, Cache: "events", Key: "key1", Value: 10},
+		{Type: EventExpire, Cache: "events", Key: "key2", Value: 20},
+	}
+
+	for _, e := range expected {
+		if got := <-c.Events(); !reflect.DeepEqual(got, e) {
+			t.Fatalf("expected %+v, but got %+v", e, got)
+		}
+	}
+
+	c.Close()
+
+	if _, ok := <-c.Events(); ok {
+		t.Fatal("expected events channel to be closed")
+	}
+}
+
+func TestCacheEventsDropped(t *testing.T) {
+
+	t.Parallel()
+
+	newest := New(1*time.Second, WithEvents[int, int](2, DropNewest))
+	oldest := New(1*time.Second, WithEvents[int, int](2, DropOldest))
+
+	for i := 0; i < 5; i++ {
+		newest.Set(i, i, 5*time.Second)
+		oldest.Set(i, i, 5*time.Second)
+	}
+
+	if dropped := newest.Stats().DroppedEvents; dropped != 3 {
+		t.Fatalf("expected 3 dropped events, but got %d", dropped)
+	}
+	if dropped := oldest.Stats().DroppedEvents; dropped != 3 {
+		t.Fatalf("expected 3 dropped events, but got %d", dropped)
+	}
+
+	if e := <-newest.Events(); e.Key != 0 {
+		t.Fatalf("expected the oldest event to be kept, but got key %d", e.Key)
+	}
+	if e := <-oldest.Events(); e.Key != 3 {
+		t.Fatalf("expected the newest events to be kept, but got key %d", e.Key)
+	}
+}
+
+func TestCacheSubscribe(t *testing.T) {
+
+	t.Parallel()
+
+	c := New(1*time.Second,
+		WithName[string, int]("subs"),
+		WithManualCleanup[string, int](),
+	)
+
+	first, cancelFirst := c.Subscribe("key1")
+	second, cancelSecond := c.Subscribe("key1")
+	defer cancelSecond()
+
+	c.Set("key1", 10, 5*time.Second)
+	c.Set("key2", 20, 5*time.Second)
+	c.Remove("key1")
+	c.Set("key1", 30, 0*time.Second)
+	c.RunCleanup()
+	c.Set("key1", 40, 5*time.Second)
+	c.Clear()
+
+	// should deliver only the events of key1, in order, to every subscriber,
+	// including the removal by Clear.
+	expected := []Event[string, int]{
+		{Type: EventSet, Cache: "subs", Key: "key1", Value: 10},
+		{Type: EventRemove, Cache: "subs", Key: "key1", Value: 10},
+		{Type: EventSet, Cache: "subs", Key: "key1", Value: 30},
+		{Type: EventExpire, Cache: "subs", Key: "key1", Value: 30},
+		{Type: EventSet, Cache: "subs", Key: "key1", Value: 40},
+		{Type: EventRemove, Cache: "subs", Key: "key1", Value: 40},
+	}
+
+	for _, ch := range []<-chan Event[string, int]{first, second} {
+		for _, e := range expected {
+			select {
+			case got := <-ch:
+				if !reflect.DeepEqual(got, e) {
+					t.Fatalf("expected %+v, but got %+v", e, got)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("expected %+v, but got nothing", e)
+			}
+		}
+	}
+
+	// should close the channel once cancelled.
+	cancelFirst()
+	if _, ok := <-first; ok {
+		t.Fatal("expected the cancelled channel to be closed")
+	}
+	if len(c.subscribers["key1"]) != 1 {
+		t.Fatalf("expected 1 remaining subscriber, but got %d", len(c.subscribers["key1"]))
+	}
+
+	c.Close()
+	if _, ok := <-second; ok {
+		t.Fatal("expected the channel to be closed by Close")
+	}
+}
+
+func TestCacheBatchedExpiry(t *testing.T) {
+
+	t.Parallel()
+
+	c := New(1*time.Second,
+		WithManualCleanup[string, int](),
+		WithEvents[string, int](10, DropNewest),
+		WithBatchedExpiry[string, int](),
+	)
+
+	c.Set("key1", 10, 0*time.Second)
+	c.Set("key2", 20, 0*time.Second)
+	c.Set("key3", 30, 5*time.Second)
+
+	for range 3 {
+		<-c.Events()
+	}
+
+	c.RunCleanup()
+	c.RunCleanup()
+
+	// should publish a single event for the pass, and none for an empty one.
+	e := <-c.Events()
+	slices.Sort(e.Keys)
+
+	if e.Type != EventBatchExpire || !slices.Equal(e.Keys, []string{"key1", "key2"}) {
+		t.Fatalf("expected a batch of key1 and key2, but got %+v", e)
+	}
+	if n := len(c.Events()); n != 0 {
+		t.Fatalf("expected no further events, but got %d", n)
+	}
+
+	// should still publish lazy expirations individually.
+	c.Set("key4", 40, 0*time.Second)
+	<-c.Events()
+	c.Get("key4")
+
+	if e := <-c.Events(); e.Type != EventExpire || e.Key != "key4" {
+		t.Fatalf("expected an expire event for key4, but got %+v", e)
+	}
+}
@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"container/list"
+	"math/rand/v2"
+	"time"
+)
+
+// EvictionPolicy chooses the item to evict when a cache bounded by
+// WithCapacity is full. The cache calls its methods with the write lock
+// held, so implementations need no synchronization of their own.
+type EvictionPolicy[K comparable] interface {
+	// Touch records that key was stored or read. The expiry is the item's
+	// current deadline.
+	Touch(key K, expiry time.Time)
+
+	// Remove forgets key after it has left the cache.
+	Remove(key K)
+
+	// Victim returns the key that should be evicted next, or false if
+	// there is nothing to evict.
+	Victim() (K, bool)
+}
+
+// RandomEviction evicts a randomly chosen key. It does no bookkeeping on
+// reads, which makes it a cheap policy for simple bounded caches.
+type RandomEviction[K comparable] struct {
+	samples int
+	keys    []K
+	expiry  []time.Time
+	index   map[K]int
+
+	// rng is the random source set with WithRandSource, or nil for the
+	// global one.
+	rng *rand.Rand
+}
+
+// NewRandomEviction returns a random eviction policy. With samples greater
+// than one, it picks that many random candidates and evicts the one closest
+// to expiry, the way Redis approximates volatile-ttl eviction.
+func NewRandomEviction[K comparable](samples int) *RandomEviction[K] {
+	return &RandomEviction[K]{
+		samples: samples,
+		index:   make(map[K]int),
+	}
+}
+
+// Len returns the number of keys tracked by the policy.
+func (p *RandomEviction[K]) Len() int {
+	return len(p.keys)
+}
+
+// Touch implements EvictionPolicy.
+func (p *RandomEviction[K]) Touch(key K, expiry time.Time) {
+
+	if n, found := p.index[key]; found {
+		p.expiry[n] = expiry
+		return
+	}
+
+	p.index[key] = len(p.keys)
+	p.keys = append(p.keys, key)
+	p.expiry = append(p.expiry, expiry)
+}
+
+// Remove implements EvictionPolicy.
+func (p *RandomEviction[K]) Remove(key K) {
+
+	n, found := p.index[key]
+	if !found {
+		return
+	}
+
+	last := len(p.keys) - 1
+	p.keys[n], p.expiry[n] = p.keys[last], p.expiry[last]
+	p.index[p.keys[n]] = n
+
+	var zero K
+	p.keys[last] = zero
+	p.keys, p.expiry = p.keys[:last], p.expiry[:last]
+	delete(p.index, key)
+}
+
+// Victim implements EvictionPolicy.
+func (p *RandomEviction[K]) Victim() (K, bool) {
+
+	if len(p.keys) == 0 {
+		var zero K
+		return zero, false
+	}
+
+	victim := p.intN(len(p.keys))
+	for s := 1; s < p.samples; s++ {
+		if n := p.intN(len(p.keys)); expiresBefore(p.expiry[n], p.expiry[victim]) {
+			victim = n
+		}
+	}
+
+	return p.keys[victim], true
+}
+
+// intN returns a random index in [0, n).
+func (p *RandomEviction[K]) intN(n int) int {
+
+	if p.rng == nil {
+		return rand.IntN(n)
+	}
+
+	return p.rng.IntN(n)
+}
+
+// expiresBefore reports whether expiry a comes before b, where the zero time
+// means never.
+func expiresBefore(a, b time.Time) bool {
+	return !a.IsZero() && (b.IsZero() || a.Before(b))
+}
+
+// LRUEviction evicts the least recently stored or read key.
+type LRUEviction[K comparable] struct {
+	order *list.List
+	index map[K]*list.Element
+}
+
+// NewLRUEviction returns a least recently used eviction policy.
+func NewLRUEviction[K comparable]() *LRUEviction[K] {
+	return &LRUEviction[K]{
+		order: list.New(),
+		index: make(map[K]*list.Element),
+	}
+}
+
+// Len returns the number of keys tracked by the policy.
+func (p *LRUEviction[K]) Len() int {
+	return p.order.Len()
+}
+
+// Touch implements EvictionPolicy.
+func (p *LRUEviction[K]) Touch(key K, expiry time.Time) {
+
+	if e, found := p.index[key]; found {
+		p.order.MoveToFront(e)
+		return
+	}
+
+	p.index[key] = p.order.PushFront(key)
+}
+
+// Remove implements EvictionPolicy.
+func (p *LRUEviction[K]) Remove(key K) {
+
+	if e, found := p.index[key]; found {
+		p.order.Remove(e)
+		delete(p.index, key)
+	}
+}
+
+// Victim implements EvictionPolicy.
+func (p *LRUEviction[K]) Victim() (K, bool) {
+
+	e := p.order.Back()
+	if e == nil {
+		var zero K
+		return zero, false
+	}
+
+	return e.Value.(K), true
+}
@@ -0,0 +1,122 @@
+package cache
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCacheRandomEviction(t *testing.T) {
+
+	t.Parallel()
+
+	policy := NewRandomEviction[int](5)
+
+	c := New(1*time.Second,
+		WithCapacity[int, int](10),
+		WithEvictionPolicy[int, int](policy),
+	)
+
+	for i := 0; i < 1000; i++ {
+		key := rand.IntN(100)
+		c.Set(key, i, time.Duration(rand.IntN(10)+1)*time.Second)
+
+		c.mu.RLock()
+		size, tracked := len(c.items), len(policy.keys)
+		c.mu.RUnlock()
+
+		if size > 10 {
+			t.Fatalf("expected at most 10 items, but got %d", size)
+		}
+		if tracked != size {
+			t.Fatalf("expected the policy to track %d keys, but got %d", size, tracked)
+		}
+	}
+}
+
+func TestRandomEvictionSampling(t *testing.T) {
+
+	t.Parallel()
+
+	policy := NewRandomEviction[string](100)
+	now := time.Now()
+
+	policy.Touch("soon", now.Add(1*time.Second))
+	policy.Touch("later", now.Add(1*time.Minute))
+
+	// should almost certainly sample the key closest to expiry.
+	if victim, ok := policy.Victim(); !ok || victim != "soon" {
+		t.Fatalf("expected soon, but got %v, ok: %v", victim, ok)
+	}
+
+	policy.Remove("soon")
+	policy.Remove("later")
+
+	if _, ok := policy.Victim(); ok {
+		t.Fatal("expected no victim in an empty policy")
+	}
+}
+
+func TestCacheRandSource(t *testing.T) {
+
+	t.Parallel()
+
+	run := func(seed uint64) []int {
+		c := New(1*time.Second,
+			WithCapacity[int, int](10),
+			WithRandSource[int, int](rand.NewPCG(seed, seed)),
+		)
+		for i := range 100 {
+			c.Set(i, i, 5*time.Second)
+		}
+		return SortedKeys(c)
+	}
+
+	// should evict the same keys for the same seed.
+	first, second := run(1), run(1)
+	if !slices.Equal(first, second) {
+		t.Fatalf("expected %v, but got %v", first, second)
+	}
+}
+
+func TestNewLRU(t *testing.T) {
+
+	t.Parallel()
+
+	c := NewLRU[int, int](3)
+
+	c.Set(1, 10, 0)
+	c.Set(2, 20, 0)
+	c.Set(3, 30, 0)
+
+	// should refresh the recency of 1, leaving 2 as the least recently used.
+	c.Get(1)
+	c.Set(4, 40, 0)
+
+	if _, found := c.Get(2); found {
+		t.Fatal("expected 2 to be evicted")
+	}
+	for _, key := range []int{1, 3, 4} {
+		if _, found := c.Get(key); !found {
+			t.Fatalf("expected %d to be kept", key)
+		}
+	}
+
+	// should keep items regardless of the TTL they were set with.
+	time.Sleep(10 * time.Millisecond)
+	if n := c.Len(); n != 3 {
+		t.Fatalf("expected 3 items, but got %d", n)
+	}
+
+	if info, _ := c.Inspect(1); !info.Expiry.IsZero() {
+		t.Fatalf("expected no expiry, but got %v", info.Expiry)
+	}
+	if s := c.Export(); len(s.Entries) != 3 || s.Entries[0].TTL != math.MaxInt64 {
+		t.Fatalf("expected 3 never-expiring entries, but got %v", s.Entries)
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+// Package httpcache provides an HTTP middleware that caches responses in a
+// go-cache Cache, honoring the max-age directive sent by the handler.
+package httpcache
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	cache "github.com/abenk-oss/go-cache"
+)
+
+// CachedResponse is a response stored by the middleware.
+type CachedResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Handler returns a middleware that serves GET and HEAD requests from c,
+// keyed by method and URL. Responses from next are stored only when they
+// carry a positive Cache-Control max-age (or s-maxage, which takes
+// precedence) and no no-store, no-cache or private directive. Each stored
+// response expires after its own max-age.
+//
+// Since the cache is shared by every client, responses carrying a Vary
+// header are never stored, and requests carrying an Authorization header
+// are only served and stored responses that explicitly allow shared
+// caching with a public, s-maxage or must-revalidate directive, as required
+// by RFC 9111 section 3.5.
+func Handler(c *cache.Cache[string, CachedResponse], next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := r.Method + " " + r.URL.String()
+		authorized := r.Header.Get("Authorization") != ""
+
+		if res, found := c.Get(key); found && (!authorized || shared(res.Header.Get("Cache-Control"))) {
+			for name, values := range res.Header {
+				w.Header()[name] = values
+			}
+			w.WriteHeader(res.StatusCode)
+			w.Write(res.Body)
+			return
+		}
+
+		rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		header := rec.Header()
+		if len(header.Values("Vary")) > 0 {
+			return
+		}
+		if authorized && !shared(header.Get("Cache-Control")) {
+			return
+		}
+
+		if ttl := maxAge(header.Get("Cache-Control")); ttl > 0 {
+			c.Set(key, CachedResponse{
+				StatusCode: rec.status,
+				Header:     header.Clone(),
+				Body:       rec.body.Bytes(),
+			}, ttl)
+		}
+	})
+}
+
+// shared reports whether a Cache-Control header value allows a shared cache
+// to store and serve a response to a request carrying an Authorization
+// header.
+func shared(header string) bool {
+
+	for _, directive := range strings.Split(header, ",") {
+		name, _, _ := strings.Cut(strings.TrimSpace(directive), "=")
+
+		switch strings.ToLower(name) {
+		case "public", "s-maxage", "must-revalidate":
+			return true
+		}
+	}
+
+	return false
+}
+
+// maxAge returns the lifetime allowed by a Cache-Control header value, or
+// zero if the response must not be cached.
+func maxAge(header string) time.Duration {
+
+	var ttl time.Duration
+	shared := false
+
+	for _, directive := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		name = strings.ToLower(name)
+
+		switch name {
+		case "no-store", "no-cache", "private":
+			return 0
+		case "max-age", "s-maxage":
+			seconds, err := strconv.Atoi(strings.Trim(value, `"`))
+			if err != nil {
+				return 0
+			}
+			if name == "s-maxage" || !shared {
+				ttl = time.Duration(seconds) * time.Second
+				shared = name == "s-maxage"
+			}
+		}
+	}
+
+	return ttl
+}
+
+// recorder passes a response through to the client while keeping a copy of
+// its status and body.
+type recorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *recorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	r.body.Write(p)
+	return r.ResponseWriter.Write(p)
+}
@@ -0,0 +1,169 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cache "github.com/abenk-oss/go-cache"
+)
+
+func TestHandler(t *testing.T) {
+
+	t.Parallel()
+
+	var calls atomic.Int32
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Cache-Control", "public, max-age=1")
+		w.Write([]byte("hello"))
+	})
+
+	c := cache.New[string, CachedResponse](1 * time.Second)
+	h := Handler(c, next)
+
+	get := func() string {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/greeting", nil))
+		return rec.Body.String()
+	}
+
+	if body := get(); body != "hello" {
+		t.Fatalf("expected hello, but got %q", body)
+	}
+
+	// should be served from the cache.
+	if body := get(); body != "hello" || calls.Load() != 1 {
+		t.Fatalf("expected a cached hello, but got %q after %d calls", body, calls.Load())
+	}
+
+	// Waiting for the response to expire.
+	time.Sleep(1100 * time.Millisecond)
+
+	if get(); calls.Load() != 2 {
+		t.Fatalf("expected the expired response to be fetched again, but got %d calls", calls.Load())
+	}
+}
+
+func TestHandlerAuthorization(t *testing.T) {
+
+	t.Parallel()
+
+	var calls atomic.Int32
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path == "/public" {
+			w.Header().Set("Cache-Control", "public, max-age=60")
+		} else {
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+		w.Write([]byte(r.Header.Get("Authorization")))
+	})
+
+	c := cache.New[string, CachedResponse](1 * time.Second)
+	h := Handler(c, next)
+
+	get := func(path, authorization string) string {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+
+	// should not store a response to an authorized request by default.
+	get("/private", "alice")
+	if body := get("/private", ""); body != "" || calls.Load() != 2 {
+		t.Fatalf("expected an uncached anonymous response, but got %q after %d calls", body, calls.Load())
+	}
+
+	// should not serve a stored response to an authorized request either.
+	if body := get("/private", "bob"); body != "bob" || calls.Load() != 3 {
+		t.Fatalf("expected bob, but got %q after %d calls", body, calls.Load())
+	}
+
+	// should share responses that allow it.
+	get("/public", "alice")
+	if body := get("/public", "bob"); body != "alice" || calls.Load() != 4 {
+		t.Fatalf("expected a shared alice, but got %q after %d calls", body, calls.Load())
+	}
+}
+
+func TestHandlerVary(t *testing.T) {
+
+	t.Parallel()
+
+	var calls atomic.Int32
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Cache-Control", "public, max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		w.Write([]byte(r.Header.Get("Accept-Language")))
+	})
+
+	c := cache.New[string, CachedResponse](1 * time.Second)
+	h := Handler(c, next)
+
+	get := func(language string) string {
+		req := httptest.NewRequest(http.MethodGet, "/greeting", nil)
+		req.Header.Set("Accept-Language", language)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+
+	get("fr")
+
+	// should not store a response varying on request headers.
+	if body := get("en"); body != "en" || calls.Load() != 2 {
+		t.Fatalf("expected en, but got %q after %d calls", body, calls.Load())
+	}
+}
+
+func TestShared(t *testing.T) {
+
+	t.Parallel()
+
+	tests := map[string]bool{
+		"public, max-age=60":      true,
+		"max-age=10, s-maxage=30": true,
+		"must-revalidate":         true,
+		"max-age=60":              false,
+		"":                        false,
+	}
+
+	for header, expected := range tests {
+		if allowed := shared(header); allowed != expected {
+			t.Errorf("%q: expected %v, but got %v", header, expected, allowed)
+		}
+	}
+}
+
+func TestMaxAge(t *testing.T) {
+
+	t.Parallel()
+
+	tests := map[string]time.Duration{
+		"max-age=60":              60 * time.Second,
+		"public, max-age=10":      10 * time.Second,
+		"max-age=10, s-maxage=30": 30 * time.Second,
+		"s-maxage=30, max-age=10": 30 * time.Second,
+		"no-store, max-age=60":    0,
+		"private, max-age=60":     0,
+		"max-age=abc":             0,
+		"":                        0,
+	}
+
+	for header, expected := range tests {
+		if ttl := maxAge(header); ttl != expected {
+			t.Errorf("%q: expected %v, but got %v", header, expected, ttl)
+		}
+	}
+}
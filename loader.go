@@ -0,0 +1,523 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// call is an in-flight loader invocation shared by concurrent readers of the
+// same key. The done channel is closed once value and err are set.
+type call[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// WithLoader makes Get read through to loader on a miss. The loader returns
+// the value along with the TTL it should be stored with, so each item can
+// expire on its own schedule, e.g. one derived from a backend's Cache-Control
+// max-age. Concurrent misses for the same key share a single loader call.
+// Errors are not cached.
+func WithLoader[K comparable, V any](loader func(key K) (V, time.Duration, error)) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.loader = loader
+	}
+}
+
+// ErrLoaderTimeout is returned for a load that took longer than the timeout
+// set with WithLoaderTimeout.
+var ErrLoaderTimeout = errors.New("loader timed out")
+
+// WithLoaderTimeout bounds every loader invocation to d. A load exceeding it
+// fails with ErrLoaderTimeout for the caller and every waiter, and nothing
+// is stored, so the key can be loaded again right away. Since the loader
+// can't be interrupted, it keeps running in the background until it
+// returns, and its result is discarded.
+func WithLoaderTimeout[K comparable, V any](d time.Duration) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.loaderTimeout = d
+	}
+}
+
+// WithMaxConcurrentLoads limits the number of loader invocations running at
+// the same time to n, protecting the backend from a storm of misses on
+// distinct keys, such as after a cold start. Loads beyond the limit wait for
+// a free slot, or until the context passed to Load is done. A caller that
+// gives up doesn't cancel the load, which still runs for the other callers
+// of the key once a slot frees up. A non-positive n means no limit.
+func WithMaxConcurrentLoads[K comparable, V any](n int) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		if n > 0 {
+			c.loadSlots = make(chan struct{}, n)
+		}
+	}
+}
+
+// WithStaleWhileRevalidate makes Get serve an expired item once, right
+// after it is found expired, while the loader configured with WithLoader
+// reloads it in the background. Later reads of the key wait for that reload
+// like a plain miss, so staleness is bounded to a single read per expiry.
+// Items already removed by a cleanup pass are loaded as usual.
+func WithStaleWhileRevalidate[K comparable, V any]() Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.staleWhileRevalidate = true
+	}
+}
+
+// ErrStale is returned by Load along with an expired value served because
+// the loader failed, as configured with WithServeStaleOnError. It wraps the
+// loader's error.
+var ErrStale = errors.New("serving stale value")
+
+// WithServeStaleOnError makes a read-through fall back to the last known
+// value when the loader configured with WithLoader fails: Get returns it as
+// found, and Load returns it along with ErrStale. To that end, reads and
+// cleanup passes keep expired items in place until a successful reload
+// replaces them, so they only go away when removed, evicted or cleared.
+// RemoveExpired and Compact still remove them.
+func WithServeStaleOnError[K comparable, V any]() Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.staleOnError = true
+	}
+}
+
+// WithProactiveRefresh makes every cleanup pass reload, in a single call to
+// batchLoader, all live items due to expire within threshold. Refreshed items
+// get the value returned for their key and a fresh copy of the TTL they were
+// stored with. Items missing from the result, or written or removed while the
+// loader ran, are left alone; a loader error leaves them to expire normally.
+// The loader runs without holding the lock, so reads proceed meanwhile.
+func WithProactiveRefresh[K comparable, V any](threshold time.Duration, batchLoader func(keys []K) (map[K]V, error)) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.refreshThreshold = threshold
+		c.refreshLoader = batchLoader
+	}
+}
+
+// refreshAhead reloads the items close to expiry through the batch loader
+// configured with WithProactiveRefresh.
+func (c *Cache[K, V]) refreshAhead() {
+
+	if c.refreshLoader == nil {
+		return
+	}
+
+	c.mu.RLock()
+
+	if c.frozen {
+		c.mu.RUnlock()
+		return
+	}
+
+	due := make(map[K]uint64)
+	now := Now()
+
+	for key, i := range c.items {
+		if remaining := i.remaining(now); remaining >= 0 && remaining < c.refreshThreshold {
+			due[key] = i.version
+		}
+	}
+
+	c.mu.RUnlock()
+
+	if len(due) == 0 {
+		return
+	}
+
+	keys := make([]K, 0, len(due))
+	for key := range due {
+		keys = append(keys, key)
+	}
+
+	values, err := c.refreshLoader(keys)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed || c.frozen {
+		return
+	}
+
+	now = Now()
+
+	// The reloaded value replaces the old one in place, so the item keeps
+	// the rest of its state, such as being sticky or its idle timeout.
+	for key, value := range values {
+		i, found := c.items[key]
+		if version, requested := due[key]; !requested || !found || i.version != version {
+			continue
+		}
+		i.value = value
+		i.expiry = c.expiryAfter(now, i.ttl)
+		i.created = now
+		i.stale = false
+		c.store(key, i)
+	}
+}
+
+// Load returns the value stored under key like Get, reading through to the
+// loader configured with WithLoader on a miss. Unlike Get, it reports the
+// loader's error, and it gives up waiting for a load slot or for another
+// goroutine's load of the same key when ctx is done. Without a loader, a
+// miss is reported as an error. With WithServeStaleOnError, a loader error
+// is wrapped in ErrStale and returned along with the expired value.
+func (c *Cache[K, V]) Load(ctx context.Context, key K) (V, error) {
+
+	value, source, err := c.read(ctx, key, false)
+	if source == SourceMiss && err == nil {
+		return value, fmt.Errorf("item %v doesn't exist", key)
+	}
+
+	return value, err
+}
+
+// Source tells where GetWithSource found a value.
+type Source int
+
+const (
+	// SourceHit reports a value found in the cache, including one recalled
+	// from the backend set with WithSpill or served stale.
+	SourceHit Source = iota
+
+	// SourceLoaded reports a value obtained from the loader during the call,
+	// including when the call waited for another goroutine's load of the
+	// same key.
+	SourceLoaded
+
+	// SourceMiss reports that no value was found or loaded.
+	SourceMiss
+)
+
+// GetWithSource retrieves the value stored under key like Get, also telling
+// where the value came from, e.g. to compute hit ratios of a read-through
+// cache. Unlike Get, it reports the loader's error, along with SourceMiss,
+// or along with the expired value and SourceHit, wrapped in ErrStale, with
+// WithServeStaleOnError. Without a loader, a miss has no error.
+func (c *Cache[K, V]) GetWithSource(key K) (V, Source, error) {
+	return c.read(context.Background(), key, false)
+}
+
+// read is the read path shared by Get, GetWithSource, GetWithContext and
+// Load: with WithStaleWhileRevalidate, an expired item is served once while
+// it reloads, and a miss is recalled from the backend set with WithSpill or
+// loaded through the loader with ctx. With lockCtx, the lock is acquired
+// through lockContext, whose error is returned along with SourceMiss.
+func (c *Cache[K, V]) read(ctx context.Context, key K, lockCtx bool) (V, Source, error) {
+
+	if lockCtx {
+		if err := c.lockContext(ctx); err != nil {
+			var zero V
+			return zero, SourceMiss, err
+		}
+	} else {
+		c.mu.Lock()
+	}
+
+	value, found := c.serveStale(key)
+	if found {
+		c.mu.Unlock()
+		c.observeRead(key, true)
+		return c.clone(value), SourceHit, nil
+	}
+
+	value, found = c.lookup(key)
+	c.mu.Unlock()
+	c.observeRead(key, found)
+
+	if found {
+		return c.clone(value), SourceHit, nil
+	}
+	if value, found := c.recall(key); found {
+		return c.clone(value), SourceHit, nil
+	}
+	if c.loader == nil {
+		return value, SourceMiss, nil
+	}
+
+	value, err := c.load(ctx, key)
+	if err != nil {
+		if value, found := c.stale(key); found {
+			return c.clone(value), SourceHit, fmt.Errorf("%w: %w", ErrStale, err)
+		}
+		return value, SourceMiss, err
+	}
+
+	return c.clone(value), SourceLoaded, nil
+}
+
+// WarmKeys loads the keys not already cached through the loader configured
+// with WithLoader, one after the other, storing each value as it arrives,
+// e.g. to populate the cache at startup from a goroutine of its own. It
+// stops once ctx is done, keeping the values loaded so far, and returns
+// ctx.Err(). Otherwise it returns the errors of the failed loads, joined,
+// after trying every key. The batch loader configured with
+// WithProactiveRefresh is not used, since it doesn't return TTLs.
+func (c *Cache[K, V]) WarmKeys(ctx context.Context, keys []K) error {
+
+	if c.loader == nil {
+		return errors.New("no loader configured")
+	}
+
+	var errs []error
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		c.mu.RLock()
+		i, found := c.items[key]
+		cached := found && !c.expired(key, i)
+		c.mu.RUnlock()
+
+		if cached {
+			continue
+		}
+
+		if _, err := c.load(ctx, key); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			errs = append(errs, fmt.Errorf("loading item %v: %w", key, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// stale returns the expired value still stored under key when
+// WithServeStaleOnError is set.
+func (c *Cache[K, V]) stale(key K) (V, bool) {
+
+	if !c.staleOnError {
+		var zero V
+		return zero, false
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	i, found := c.items[key]
+	return i.value, found
+}
+
+// keepsStale reports whether expired items are kept in place as a fallback
+// for loader errors, as configured with WithServeStaleOnError.
+func (c *Cache[K, V]) keepsStale() bool {
+	return c.staleOnError && c.loader != nil
+}
+
+// serveStale returns the value of the expired item stored under key if it
+// hasn't been served stale yet, marking it as served and starting a
+// background reload. It only applies with WithStaleWhileRevalidate and a
+// loader, and the caller must hold the write lock.
+func (c *Cache[K, V]) serveStale(key K) (V, bool) {
+
+	i, found := c.items[key]
+	if !c.staleWhileRevalidate || c.loader == nil || !found || i.stale || !c.expired(key, i) || c.closed || c.frozen {
+		var zero V
+		return zero, false
+	}
+
+	i.stale = true
+	c.items[key] = i
+
+	// The call is registered before returning, so a read following this
+	// one waits for it instead of starting a second reload.
+	if cl, leader := c.join(key); leader {
+		go c.run(key, cl)
+	}
+
+	return i.value, true
+}
+
+// load invokes the loader for key, collapsing concurrent calls for the same
+// key into one, and stores a successfully loaded value with the TTL returned
+// by the loader. With WithMaxConcurrentLoads, the invocation first waits for
+// a free load slot on its own goroutine, so that a leader whose ctx is done
+// only gives up itself, leaving the call to the other waiters.
+func (c *Cache[K, V]) load(ctx context.Context, key K) (V, error) {
+
+	cl, leader := c.join(key)
+	if leader {
+		if c.loadSlots == nil {
+			return c.run(key, cl)
+		}
+		go c.run(key, cl)
+	}
+
+	select {
+	case <-cl.done:
+		return cl.value, cl.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// join returns the in-flight loader call for key, registering a new one if
+// there is none, in which case the caller is the leader and must run it.
+func (c *Cache[K, V]) join(key K) (*call[V], bool) {
+
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+
+	if cl, found := c.calls[key]; found {
+		return cl, false
+	}
+
+	cl := &call[V]{done: make(chan struct{})}
+
+	if c.calls == nil {
+		c.calls = make(map[K]*call[V])
+	}
+	c.calls[key] = cl
+
+	return cl, true
+}
+
+// run invokes the loader for the call cl registered by join and releases
+// its waiters, waiting for a free load slot first if loads are limited.
+func (c *Cache[K, V]) run(key K, cl *call[V]) (V, error) {
+
+	defer func() {
+		c.loadMu.Lock()
+		delete(c.calls, key)
+		c.loadMu.Unlock()
+
+		close(cl.done)
+	}()
+
+	release := func() {}
+	if c.loadSlots != nil {
+		c.loadSlots <- struct{}{}
+		release = func() { <-c.loadSlots }
+	}
+
+	value, ttl, err := c.invoke(key, release)
+	cl.value, cl.err = value, err
+
+	if err == nil {
+		c.mu.Lock()
+		if !c.closed && !c.frozen {
+			c.set(key, value, ttl)
+		}
+		c.mu.Unlock()
+	}
+
+	return value, err
+}
+
+// loaded is the result of a loader invocation.
+type loaded[V any] struct {
+	value V
+	ttl   time.Duration
+	err   error
+}
+
+// invoke calls the loader for key, giving up after the timeout configured
+// with WithLoaderTimeout. release is called once the loader returns, even
+// if invoke gave up on it, so an abandoned loader keeps its load slot.
+func (c *Cache[K, V]) invoke(key K, release func()) (V, time.Duration, error) {
+
+	if c.loaderTimeout <= 0 {
+		defer release()
+		return c.loader(key)
+	}
+
+	ch := make(chan loaded[V], 1)
+	go func() {
+		defer release()
+		value, ttl, err := c.loader(key)
+		ch <- loaded[V]{value, ttl, err}
+	}()
+
+	timer := time.NewTimer(c.loaderTimeout)
+	defer timer.Stop()
+
+	select {
+	case r := <-ch:
+		return r.value, r.ttl, r.err
+	case <-timer.C:
+		var zero V
+		return zero, 0, fmt.Errorf("loading item %v: %w", key, ErrLoaderTimeout)
+	}
+}
+
+// GetOrLoadNoCache returns the live value stored under key, reporting true
+// for cached. On a miss, it returns the result of loader without storing it,
+// keeping one-off reads from taking up cache space. The configured WithLoader
+// function, if any, is not used.
+func (c *Cache[K, V]) GetOrLoadNoCache(key K, loader func() (V, error)) (value V, cached bool, err error) {
+
+	if value, found := c.get(key); found {
+		return c.clone(value), true, nil
+	}
+
+	value, err = loader()
+	return value, false, err
+}
+
+// Memoize returns a function caching the results of fn for ttl. Concurrent
+// calls for a key that isn't cached share a single call to fn, and errors
+// are returned without being cached. The results are held in a cache whose
+// cleanup goroutine sweeps expired results every ttl and runs for the rest
+// of the program, so Memoize is meant for functions wrapped once, such as at
+// package initialization. It panics if ttl is not positive.
+func Memoize[K comparable, V any](ttl time.Duration, fn func(key K) (V, error)) func(key K) (V, error) {
+
+	if ttl <= 0 {
+		panic(fmt.Errorf("memoize ttl must be positive, got %v", ttl))
+	}
+
+	c := New(ttl, WithLoader[K, V](func(key K) (V, time.Duration, error) {
+		value, err := fn(key)
+		return value, ttl, err
+	}))
+
+	return func(key K) (V, error) {
+		return c.Load(context.Background(), key)
+	}
+}
+
+// args2 and args3 are the keys used by Memoize2 and Memoize3.
+type args2[A, B comparable] struct {
+	a A
+	b B
+}
+
+type args3[A, B, C comparable] struct {
+	a A
+	b B
+	c C
+}
+
+// Memoize2 is like Memoize for a function of two arguments. Results are
+// cached per pair of arguments, compared field by field as a struct, so no
+// key needs to be built by hand.
+func Memoize2[A, B comparable, V any](ttl time.Duration, fn func(a A, b B) (V, error)) func(a A, b B) (V, error) {
+
+	memoized := Memoize(ttl, func(key args2[A, B]) (V, error) {
+		return fn(key.a, key.b)
+	})
+
+	return func(a A, b B) (V, error) {
+		return memoized(args2[A, B]{a, b})
+	}
+}
+
+// Memoize3 is like Memoize2 for a function of three arguments.
+func Memoize3[A, B, C comparable, V any](ttl time.Duration, fn func(a A, b B, c C) (V, error)) func(a A, b B, c C) (V, error) {
+
+	memoized := Memoize(ttl, func(key args3[A, B, C]) (V, error) {
+		return fn(key.a, key.b, key.c)
+	})
+
+	return func(a A, b B, c C) (V, error) {
+		return memoized(args3[A, B, C]{a, b, c})
+	}
+}
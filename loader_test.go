@@ -0,0 +1,642 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCacheLoaderPerItemTTL(t *testing.T) {
+
+	t.Parallel()
+
+	var calls atomic.Int32
+
+	c := New(1*time.Second, WithLoader(func(key string) (int, time.Duration, error) {
+		calls.Add(1)
+		if key == "short" {
+			return 1, 50 * time.Millisecond, nil
+		}
+		return 2, 5 * time.Second, nil
+	}))
+
+	if value, found := c.Get("short"); !found || value != 1 {
+		t.Fatalf("expected 1, but got %v, found: %v", value, found)
+	}
+	if value, found := c.Get("long"); !found || value != 2 {
+		t.Fatalf("expected 2, but got %v, found: %v", value, found)
+	}
+
+	// should be served from the cache.
+	c.Get("short")
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("expected 2 loader calls, but got %d", n)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	_, found := c.GetOrdered([]string{"short", "long"})
+	if found[0] || !found[1] {
+		t.Fatalf("expected only the short-lived item to expire, but got %v", found)
+	}
+}
+
+func TestCacheLoaderSharedCall(t *testing.T) {
+
+	t.Parallel()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	c := New(1*time.Second, WithLoader(func(key string) (int, time.Duration, error) {
+		calls.Add(1)
+		<-release
+		return 10, 5 * time.Second, nil
+	}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if value, found := c.Get("key1"); !found || value != 10 {
+				t.Errorf("expected 10, but got %v, found: %v", value, found)
+			}
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected 1 loader call, but got %d", n)
+	}
+}
+
+func TestCacheLoaderError(t *testing.T) {
+
+	t.Parallel()
+
+	c := New(1*time.Second, WithLoader(func(key string) (int, time.Duration, error) {
+		return 0, 0, errors.New("backend down")
+	}))
+
+	if _, found := c.Get("key1"); found {
+		t.Fatal("expected loader error to be reported as a miss")
+	}
+	if len(c.items) != 0 {
+		t.Fatal("expected loader error not to be cached")
+	}
+}
+
+func TestCacheGetOrLoadNoCache(t *testing.T) {
+
+	t.Parallel()
+
+	c := New[string, int](1 * time.Second)
+
+	c.Set("key1", 10, 5*time.Second)
+
+	loader := func() (int, error) {
+		return 20, nil
+	}
+
+	if value, cached, err := c.GetOrLoadNoCache("key1", loader); err != nil || !cached || value != 10 {
+		t.Fatalf("expected cached 10, but got %v, cached: %v, err: %v", value, cached, err)
+	}
+
+	if value, cached, err := c.GetOrLoadNoCache("key2", loader); err != nil || cached || value != 20 {
+		t.Fatalf("expected loaded 20, but got %v, cached: %v, err: %v", value, cached, err)
+	}
+
+	// should not be stored.
+	if _, found := c.Get("key2"); found {
+		t.Fatal("expected loaded value not to be cached")
+	}
+
+	failing := func() (int, error) {
+		return 0, errors.New("backend down")
+	}
+	if _, _, err := c.GetOrLoadNoCache("key2", failing); err == nil {
+		t.Fatal("expected loader error, but got none")
+	}
+}
+
+func TestCacheProactiveRefresh(t *testing.T) {
+
+	t.Parallel()
+
+	var requested []string
+
+	c := New(1*time.Second,
+		WithManualCleanup[string, int](),
+		WithProactiveRefresh(1*time.Second, func(keys []string) (map[string]int, error) {
+			requested = keys
+			values := make(map[string]int)
+			for _, key := range keys {
+				values[key] = 100
+			}
+			return values, nil
+		}),
+	)
+
+	c.SetSticky("soon", 1, 500*time.Millisecond)
+	c.Set("later", 2, 1*time.Minute)
+
+	time.Sleep(300 * time.Millisecond)
+
+	c.RunCleanup()
+
+	if len(requested) != 1 || requested[0] != "soon" {
+		t.Fatalf("expected only soon to be refreshed, but got %v", requested)
+	}
+
+	// should outlive its original expiry after being reloaded with a fresh TTL.
+	time.Sleep(300 * time.Millisecond)
+
+	if value, found := c.Get("soon"); !found || value != 100 {
+		t.Fatalf("expected refreshed 100, but got %v, found: %v", value, found)
+	}
+	if value, _ := c.Get("later"); value != 2 {
+		t.Fatalf("expected later to be untouched, but got %v", value)
+	}
+
+	// should keep the refreshed item sticky.
+	c.Clear()
+	if value, found := c.Get("soon"); !found || value != 100 {
+		t.Fatalf("expected sticky 100 to survive Clear, but got %v, found: %v", value, found)
+	}
+}
+
+func TestCacheMaxConcurrentLoads(t *testing.T) {
+
+	t.Parallel()
+
+	var running, peak atomic.Int32
+
+	c := New(1*time.Second,
+		WithMaxConcurrentLoads[int, int](3),
+		WithLoader(func(key int) (int, time.Duration, error) {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			running.Add(-1)
+			return key, 5 * time.Second, nil
+		}),
+	)
+
+	var wg sync.WaitGroup
+	for key := 0; key < 20; key++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if value, err := c.Load(context.Background(), key); err != nil || value != key {
+				t.Errorf("expected %d, but got %v, err: %v", key, value, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if p := peak.Load(); p != 3 {
+		t.Fatalf("expected at most 3 concurrent loads, but got %d", p)
+	}
+
+	// should give up waiting for a load slot.
+	release := make(chan struct{})
+	defer close(release)
+
+	blocked := New(1*time.Second,
+		WithMaxConcurrentLoads[int, int](1),
+		WithLoader(func(key int) (int, time.Duration, error) {
+			if key == 0 {
+				<-release
+			}
+			return key, 5 * time.Second, nil
+		}),
+	)
+
+	go blocked.Load(context.Background(), 0)
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := blocked.Load(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, but got %v", err)
+	}
+
+	// should not limit loads for a non-positive n.
+	for _, n := range []int{0, -1} {
+		unlimited := New(1*time.Second,
+			WithMaxConcurrentLoads[int, int](n),
+			WithLoader(func(key int) (int, time.Duration, error) {
+				return key, 5 * time.Second, nil
+			}),
+		)
+		if value, found := unlimited.Get(7); !found || value != 7 {
+			t.Fatalf("%d: expected 7, but got %v, found: %v", n, value, found)
+		}
+	}
+}
+
+func TestCacheMaxConcurrentLoadsLeaderGivesUp(t *testing.T) {
+
+	t.Parallel()
+
+	release := make(chan struct{})
+
+	c := New(1*time.Second,
+		WithMaxConcurrentLoads[int, int](1),
+		WithLoader(func(key int) (int, time.Duration, error) {
+			if key == 0 {
+				<-release
+			}
+			return key * 10, 5 * time.Second, nil
+		}),
+	)
+
+	// key 0 holds the only load slot until released.
+	go c.Load(context.Background(), 0)
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	leader := make(chan error, 1)
+	go func() {
+		_, err := c.Load(ctx, 1)
+		leader <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	follower := make(chan int, 1)
+	go func() {
+		value, err := c.Load(context.Background(), 1)
+		if err != nil {
+			t.Errorf("expected no error, but got %v", err)
+		}
+		follower <- value
+	}()
+
+	if err := <-leader; !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, but got %v", err)
+	}
+	close(release)
+
+	// should still load the key for a follower whose context is alive.
+	if value := <-follower; value != 10 {
+		t.Fatalf("expected 10, but got %v", value)
+	}
+}
+
+func TestCacheStaleWhileRevalidate(t *testing.T) {
+
+	t.Parallel()
+
+	var calls atomic.Int32
+
+	c := New(1*time.Second,
+		WithManualCleanup[string, int](),
+		WithStaleWhileRevalidate[string, int](),
+		WithLoader(func(key string) (int, time.Duration, error) {
+			time.Sleep(50 * time.Millisecond)
+			return int(calls.Add(1)) * 100, 5 * time.Second, nil
+		}),
+	)
+
+	c.Set("key1", 10, 50*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+
+	// should serve the expired value once.
+	if value, found := c.Get("key1"); !found || value != 10 {
+		t.Fatalf("expected stale 10, but got %v, found: %v", value, found)
+	}
+
+	// should wait for the reload afterwards.
+	if value, found := c.Get("key1"); !found || value != 100 {
+		t.Fatalf("expected 100, but got %v, found: %v", value, found)
+	}
+	if value, found := c.Get("key1"); !found || value != 100 {
+		t.Fatalf("expected 100, but got %v, found: %v", value, found)
+	}
+
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected 1 reload, but got %d", n)
+	}
+
+	c.Set("key2", 20, 50*time.Millisecond)
+	c.Set("key3", 30, 50*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+
+	// should serve stale values through Load and GetWithContext too.
+	if value, err := c.Load(context.Background(), "key2"); err != nil || value != 20 {
+		t.Fatalf("expected stale 20, but got %v, err: %v", value, err)
+	}
+	if value, found, err := c.GetWithContext(context.Background(), "key3"); err != nil || !found || value != 30 {
+		t.Fatalf("expected stale 30, but got %v, found: %v, err: %v", value, found, err)
+	}
+}
+
+func TestCacheLoaderTimeout(t *testing.T) {
+
+	t.Parallel()
+
+	var calls atomic.Int32
+
+	c := New(1*time.Second,
+		WithLoaderTimeout[string, int](20*time.Millisecond),
+		WithLoader(func(key string) (int, time.Duration, error) {
+			if calls.Add(1) == 1 {
+				time.Sleep(200 * time.Millisecond)
+			}
+			return 10, 5 * time.Second, nil
+		}),
+	)
+
+	// should free every waiter with the timeout error.
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.Load(context.Background(), "key1"); !errors.Is(err, ErrLoaderTimeout) {
+				t.Errorf("expected ErrLoaderTimeout, but got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, found := c.Peek("key1"); found {
+		t.Fatal("expected key1 to remain uncached")
+	}
+
+	// should retry with a new loader call.
+	if value, err := c.Load(context.Background(), "key1"); err != nil || value != 10 {
+		t.Fatalf("expected 10, but got %v, err: %v", value, err)
+	}
+}
+
+func TestCacheServeStaleOnError(t *testing.T) {
+
+	t.Parallel()
+
+	var down atomic.Bool
+	backend := errors.New("backend down")
+
+	c := New(1*time.Second,
+		WithManualCleanup[string, int](),
+		WithServeStaleOnError[string, int](),
+		WithLoader(func(key string) (int, time.Duration, error) {
+			if down.Load() {
+				return 0, 0, backend
+			}
+			return 10, 50 * time.Millisecond, nil
+		}),
+	)
+
+	if value, found := c.Get("key1"); !found || value != 10 {
+		t.Fatalf("expected 10, but got %v, found: %v", value, found)
+	}
+
+	down.Store(true)
+	time.Sleep(100 * time.Millisecond)
+
+	// should keep the expired value through cleanup passes.
+	c.RunCleanup()
+
+	// should serve the expired value while the loader fails.
+	if value, found := c.Get("key1"); !found || value != 10 {
+		t.Fatalf("expected stale 10, but got %v, found: %v", value, found)
+	}
+	value, err := c.Load(context.Background(), "key1")
+	if value != 10 || !errors.Is(err, ErrStale) || !errors.Is(err, backend) {
+		t.Fatalf("expected stale 10 with ErrStale, but got %v, err: %v", value, err)
+	}
+
+	if _, found := c.Get("missing"); found {
+		t.Fatal("expected a key never loaded to be missing")
+	}
+}
+
+func TestMemoize(t *testing.T) {
+
+	t.Parallel()
+
+	var calls sync.Map
+	release := make(chan struct{})
+
+	fn := Memoize(100*time.Millisecond, func(key string) (int, error) {
+		n, _ := calls.LoadOrStore(key, new(atomic.Int32))
+		n.(*atomic.Int32).Add(1)
+		if key == "bad" {
+			return 0, errors.New("boom")
+		}
+		<-release
+		return len(key), nil
+	})
+
+	count := func(key string) int32 {
+		n, found := calls.Load(key)
+		if !found {
+			return 0
+		}
+		return n.(*atomic.Int32).Load()
+	}
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if value, err := fn("key1"); err != nil || value != 4 {
+				t.Errorf("expected 4, but got %v, err: %v", value, err)
+			}
+		}()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// should call fn once for concurrent and repeated calls within the TTL.
+	fn("key1")
+	if n := count("key1"); n != 1 {
+		t.Fatalf("expected 1 call, but got %d", n)
+	}
+
+	// should call fn again once the result has expired.
+	time.Sleep(150 * time.Millisecond)
+	fn("key1")
+	if n := count("key1"); n != 2 {
+		t.Fatalf("expected 2 calls, but got %d", n)
+	}
+
+	// should not cache errors.
+	fn("bad")
+	if _, err := fn("bad"); err == nil || count("bad") != 2 {
+		t.Fatalf("expected an uncached error, but got %v after %d calls", err, count("bad"))
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("expected Memoize to panic on a non-positive ttl")
+		}
+	}()
+
+	Memoize(0, func(key string) (int, error) { return 0, nil })
+}
+
+func TestMemoizeN(t *testing.T) {
+
+	t.Parallel()
+
+	var calls2, calls3 atomic.Int32
+
+	join := Memoize2(5*time.Second, func(a string, b int) (string, error) {
+		calls2.Add(1)
+		return fmt.Sprintf("%s/%d", a, b), nil
+	})
+
+	// should cache per argument pair, without collisions between pairs
+	// that would concatenate alike.
+	for range 2 {
+		if v, _ := join("a1", 2); v != "a1/2" {
+			t.Fatalf("expected a1/2, but got %v", v)
+		}
+		if v, _ := join("a", 12); v != "a/12" {
+			t.Fatalf("expected a/12, but got %v", v)
+		}
+	}
+	if n := calls2.Load(); n != 2 {
+		t.Fatalf("expected 2 calls, but got %d", n)
+	}
+
+	sum := Memoize3(5*time.Second, func(a, b, c int) (int, error) {
+		calls3.Add(1)
+		return a + b + c, nil
+	})
+
+	for range 2 {
+		for _, args := range [][3]int{{1, 2, 3}, {3, 2, 1}, {2, 2, 2}} {
+			if v, _ := sum(args[0], args[1], args[2]); v != 6 {
+				t.Fatalf("expected 6, but got %v", v)
+			}
+		}
+	}
+	if n := calls3.Load(); n != 3 {
+		t.Fatalf("expected 3 calls, but got %d", n)
+	}
+}
+
+func TestCacheGetWithSource(t *testing.T) {
+
+	t.Parallel()
+
+	errBoom := errors.New("boom")
+	release := make(chan struct{})
+
+	c := New(1*time.Second, WithLoader[string, int](func(key string) (int, time.Duration, error) {
+		if key == "bad" {
+			return 0, 0, errBoom
+		}
+		<-release
+		return 10, 5 * time.Second, nil
+	}))
+
+	// should report a load to every goroutine waiting for it.
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if value, source, err := c.GetWithSource("key1"); value != 10 || source != SourceLoaded || err != nil {
+				t.Errorf("expected 10 loaded, but got %v, source: %v, err: %v", value, source, err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if value, source, err := c.GetWithSource("key1"); value != 10 || source != SourceHit || err != nil {
+		t.Fatalf("expected a hit of 10, but got %v, source: %v, err: %v", value, source, err)
+	}
+	if _, source, err := c.GetWithSource("bad"); source != SourceMiss || !errors.Is(err, errBoom) {
+		t.Fatalf("expected a miss with errBoom, but got source: %v, err: %v", source, err)
+	}
+
+	plain := New[string, int](1 * time.Second)
+	if _, source, err := plain.GetWithSource("key1"); source != SourceMiss || err != nil {
+		t.Fatalf("expected a miss without error, but got source: %v, err: %v", source, err)
+	}
+
+	stale := New(1*time.Second,
+		WithManualCleanup[string, int](),
+		WithServeStaleOnError[string, int](),
+		WithLoader(func(key string) (int, time.Duration, error) {
+			return 0, 0, errBoom
+		}),
+	)
+	stale.Set("key1", 10, 0*time.Second)
+
+	// should agree with Get on a stale value served after a loader error.
+	if value, source, err := stale.GetWithSource("key1"); value != 10 || source != SourceHit || !errors.Is(err, ErrStale) {
+		t.Fatalf("expected a stale hit of 10, but got %v, source: %v, err: %v", value, source, err)
+	}
+	if value, found := stale.Get("key1"); !found || value != 10 {
+		t.Fatalf("expected stale 10, but got %v, found: %v", value, found)
+	}
+	if _, found := stale.Get("missing"); found {
+		t.Fatal("expected a failed load to be a miss")
+	}
+}
+
+func TestCacheWarmKeys(t *testing.T) {
+
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls atomic.Int32
+
+	c := New(1*time.Second, WithLoader[int, int](func(key int) (int, time.Duration, error) {
+		// should stop warming once cancelled after the third load.
+		if calls.Add(1) == 3 {
+			cancel()
+		}
+		return key * 10, 5 * time.Second, nil
+	}))
+
+	c.Set(0, 1, 5*time.Second)
+
+	err := c.WarmKeys(ctx, []int{0, 1, 2, 3, 4, 5})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, but got %v", err)
+	}
+
+	if n := calls.Load(); n != 3 {
+		t.Fatalf("expected 3 loads, but got %d", n)
+	}
+	if value, found := c.Peek(0); !found || value != 1 {
+		t.Fatalf("expected the cached key to be kept, but got %v, found: %v", value, found)
+	}
+	for key := 1; key <= 3; key++ {
+		if value, found := c.Peek(key); !found || value != key*10 {
+			t.Fatalf("expected key %d to be warmed, but got %v, found: %v", key, value, found)
+		}
+	}
+	if _, found := c.Peek(4); found {
+		t.Fatal("expected key 4 not to be warmed")
+	}
+}
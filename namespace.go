@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"strings"
+	"time"
+)
+
+// Namespaced is a view of a string-keyed cache that transparently prefixes
+// every key, giving logical partitions over a single shared cache. All
+// operations go through the parent cache, sharing its lock, cleanup and
+// TTL handling.
+type Namespaced[V any] struct {
+	c      *Cache[string, V]
+	prefix string
+}
+
+// namespaceSep separates the prefix of a namespace from its keys, so that
+// namespaces whose prefixes are prefixes of each other, such as "user" and
+// "users", don't see each other's keys.
+const namespaceSep = "\x00"
+
+// Namespace returns a view of c whose keys are stored in c as prefix,
+// followed by a NUL byte and the key. Prefixes must not contain NUL bytes.
+func Namespace[V any](c *Cache[string, V], prefix string) *Namespaced[V] {
+	return &Namespaced[V]{c: c, prefix: prefix + namespaceSep}
+}
+
+// Get retrieves the value stored under key in the namespace, like
+// Cache.Get.
+func (n *Namespaced[V]) Get(key string) (V, bool) {
+	return n.c.Get(n.prefix + key)
+}
+
+// Set inserts an item under key in the namespace, like Cache.Set.
+func (n *Namespaced[V]) Set(key string, data V, ttl time.Duration) {
+	n.c.Set(n.prefix+key, data, ttl)
+}
+
+// Remove removes the item stored under key in the namespace, like
+// Cache.Remove.
+func (n *Namespaced[V]) Remove(key string) {
+	n.c.Remove(n.prefix + key)
+}
+
+// Keys returns the live keys of the namespace with the prefix stripped.
+func (n *Namespaced[V]) Keys() []string {
+
+	var keys []string
+	for _, key := range n.c.Keys() {
+		if k, found := strings.CutPrefix(key, n.prefix); found {
+			keys = append(keys, k)
+		}
+	}
+
+	return keys
+}
@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestNamespace(t *testing.T) {
+
+	t.Parallel()
+
+	c := New[string, int](1 * time.Second)
+
+	users := Namespace(c, "users:")
+	orders := Namespace(c, "orders:")
+
+	users.Set("42", 1, 5*time.Second)
+	orders.Set("42", 2, 5*time.Second)
+
+	if value, found := users.Get("42"); !found || value != 1 {
+		t.Fatalf("expected 1, but got %v, found: %v", value, found)
+	}
+	if value, found := orders.Get("42"); !found || value != 2 {
+		t.Fatalf("expected 2, but got %v, found: %v", value, found)
+	}
+
+	if keys := users.Keys(); !slices.Equal(keys, []string{"42"}) {
+		t.Fatalf("expected [42], but got %v", keys)
+	}
+
+	users.Remove("42")
+
+	if _, found := users.Get("42"); found {
+		t.Fatal("expected item to be removed from the users namespace")
+	}
+	if _, found := orders.Get("42"); !found {
+		t.Fatal("expected item to remain in the orders namespace")
+	}
+
+	user := Namespace(c, "user")
+	usersOf := Namespace(c, "users")
+
+	user.Set("1", 3, 5*time.Second)
+	usersOf.Set("2", 4, 5*time.Second)
+
+	// should keep namespaces apart even if one prefix extends the other.
+	if keys := user.Keys(); !slices.Equal(keys, []string{"1"}) {
+		t.Fatalf("expected [1], but got %v", keys)
+	}
+	if _, found := user.Get("s2"); found {
+		t.Fatal("expected the users key not to be visible from user")
+	}
+}
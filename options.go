@@ -0,0 +1,394 @@
+package cache
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Option configures optional behavior of a Cache created with New.
+type Option[K comparable, V any] func(*Cache[K, V])
+
+// ClosedPolicy determines how mutating methods behave once the cache has
+// been closed.
+type ClosedPolicy int
+
+const (
+	// ClosedError makes error-returning methods report ErrClosed, while
+	// the remaining mutating methods become no-ops. This is the default.
+	ClosedError ClosedPolicy = iota
+
+	// ClosedIgnore silently drops every mutating operation, including the
+	// error-returning ones, which return nil.
+	ClosedIgnore
+
+	// ClosedPanic makes every mutating method panic with ErrClosed.
+	ClosedPanic
+)
+
+// WithClosedPolicy sets the behavior of mutating methods called after Close.
+func WithClosedPolicy[K comparable, V any](policy ClosedPolicy) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.closedPolicy = policy
+	}
+}
+
+// FrozenPolicy determines how mutating methods behave while the cache is
+// frozen.
+type FrozenPolicy int
+
+const (
+	// FrozenError makes error-returning methods report ErrFrozen, while
+	// the remaining mutating methods become no-ops. This is the default.
+	FrozenError FrozenPolicy = iota
+
+	// FrozenIgnore silently drops every mutating operation, including the
+	// error-returning ones, which return nil.
+	FrozenIgnore
+)
+
+// WithFrozenPolicy sets the behavior of mutating methods called while the
+// cache is frozen.
+func WithFrozenPolicy[K comparable, V any](policy FrozenPolicy) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.frozenPolicy = policy
+	}
+}
+
+// ReplaceExpiredPolicy determines how Replace treats an item that is stored
+// but expired.
+type ReplaceExpiredPolicy int
+
+const (
+	// ReplaceExpiredError makes Replace delete the expired item and report
+	// an error. This is the default.
+	ReplaceExpiredError ReplaceExpiredPolicy = iota
+
+	// ReplaceExpiredSet makes Replace store the new value as if the item
+	// were still live.
+	ReplaceExpiredSet
+
+	// ReplaceExpiredMissing makes Replace report an error, as for a missing
+	// key, leaving the expired item for the cleanup to remove.
+	ReplaceExpiredMissing
+)
+
+// WithReplaceExpiredPolicy sets how Replace treats expired items.
+func WithReplaceExpiredPolicy[K comparable, V any](policy ReplaceExpiredPolicy) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.replaceExpired = policy
+	}
+}
+
+// WithReplacePreservesTTL makes Replace keep the expiry of the item it
+// replaces when preserve is set, ignoring its ttl argument, so refreshing a
+// value doesn't extend its life and the item expires on its original
+// schedule. The creation time reported by Inspect is still reset, since the
+// value is new. Set keeps resetting the expiry, and Replace still uses ttl
+// for an expired item stored under ReplaceExpiredSet.
+func WithReplacePreservesTTL[K comparable, V any](preserve bool) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.replaceKeepsTTL = preserve
+	}
+}
+
+// WithSizeFunc sets the function used to estimate the size in bytes of each
+// item. The size is computed once when the item is stored and is reported
+// in aggregate by MemoryUsage.
+func WithSizeFunc[K comparable, V any](sizeOf func(key K, value V) int64) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.sizeOf = sizeOf
+	}
+}
+
+// WithManualCleanup disables the background cleanup goroutine, leaving the
+// cleanupInterval passed to New unused. Expired items are still hidden and
+// lazily removed by reads; call RunCleanup or RemoveExpired to sweep them.
+func WithManualCleanup[K comparable, V any]() Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.manualCleanup = true
+	}
+}
+
+// CleanupReport describes a completed cleanup pass.
+type CleanupReport struct {
+	// Name is the name of the cache that was swept.
+	Name string
+	// Scanned is the number of items inspected by the pass.
+	Scanned int
+	// Expired is the number of expired items removed by the pass.
+	Expired int
+	// Compacted reports whether the pass rebuilt the item map, as
+	// configured with WithAutoCompact.
+	Compacted bool
+	// Took is the duration of the pass.
+	Took time.Duration
+}
+
+// WithAutoCompact makes cleanup passes rebuild the item map, like Compact,
+// once the cache holds fewer than ratio times the most items it held since
+// the map was last rebuilt. A Go map doesn't shrink after deletions, so this
+// keeps a cache whose size swings widely from holding on to the storage of
+// its peak. The rebuild takes place under the lock of the pass.
+func WithAutoCompact[K comparable, V any](ratio float64) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.autoCompact = ratio
+	}
+}
+
+// WithAdaptiveCleanup lets the cleanup goroutine adjust its interval to the
+// rate of expiry. Starting from the cleanupInterval passed to New, the
+// interval is halved after a pass that finds more than a quarter of the
+// items expired and doubled after one that finds less than 5%, staying
+// within [minInterval, maxInterval]. A minInterval below a millisecond is
+// raised to a millisecond, so halving never drives the interval to zero.
+func WithAdaptiveCleanup[K comparable, V any](minInterval, maxInterval time.Duration) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.adaptive = true
+		c.minCleanup = max(minInterval, minAdaptiveCleanup)
+		c.maxCleanup = max(maxInterval, c.minCleanup)
+	}
+}
+
+// WithCleanupSchedule lets schedule decide, at every cycle of the cleanup
+// goroutine, how long to wait after the pass before the next cycle, e.g. to
+// sweep aggressively at night and sparingly during the day. It receives the
+// current time as given by Now. A non-positive duration skips the pass, and
+// the next cycle follows after the cleanupInterval passed to New, which also
+// sets the delay of the first cycle. The schedule takes precedence over
+// WithAdaptiveCleanup.
+func WithCleanupSchedule[K comparable, V any](schedule func(now time.Time) time.Duration) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.schedule = schedule
+	}
+}
+
+// WithReadObserver registers a function invoked on every Get with whether
+// the key was found in the cache, before any loader runs for a miss. It runs
+// outside the lock on the caller's goroutine, e.g. to export hit and miss
+// metrics.
+func WithReadObserver[K comparable, V any](observer func(key K, hit bool)) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.readObserver = observer
+	}
+}
+
+// WithCleanupObserver registers a function invoked with a report after every
+// cleanup pass. It runs outside the lock on the cleanup goroutine, or on the
+// caller's goroutine for RunCleanup.
+func WithCleanupObserver[K comparable, V any](observer func(CleanupReport)) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.cleanupObserver = observer
+	}
+}
+
+// WithSweepHandler registers a function invoked with every item removed by
+// a cleanup pass, after the pass has released the lock. Items found expired
+// and removed lazily by other operations are not passed to the handler.
+func WithSweepHandler[K comparable, V any](handler func(key K, value V)) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.sweepHandler = handler
+	}
+}
+
+// WithKeyComparator makes Keys and Export return keys in the order defined
+// by compare, which reports whether a sorts before, after or equal to b
+// like cmp.Compare. This gives reproducible output for diffing and tests at
+// the cost of sorting on every call.
+func WithKeyComparator[K comparable, V any](compare func(a, b K) int) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.compareKeys = compare
+	}
+}
+
+// WithContextLocking makes GetWithContext, SetWithContext and
+// RemoveWithContext give up waiting for a contended lock once their context
+// is done. Since sync.RWMutex can't be cancelled, they poll it with TryLock
+// every poll interval instead of blocking. This trades latency and CPU for
+// cancellability: a waiter notices the lock was released up to poll late,
+// and polling waiters don't queue, so under sustained contention they can
+// be starved by goroutines taking the lock through the blocking methods.
+func WithContextLocking[K comparable, V any](poll time.Duration) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.lockPoll = poll
+	}
+}
+
+// WithEmptyStateCallback registers a function invoked when the cache goes
+// from empty to holding items, with false, and when it loses its last item,
+// with true. Expired items still count as held until they are removed. The
+// function runs under the cache lock, so it observes transitions in order,
+// and must not call back into the cache.
+func WithEmptyStateCallback[K comparable, V any](callback func(empty bool)) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.onEmptyState = callback
+	}
+}
+
+// WithName sets the name identifying the cache. It is returned by Name and
+// reported as CleanupReport.Name, Stats.Name and Event.Cache, places the
+// cache on a Ring, and becomes the cache.name attribute recorded by the
+// otelcache package. Caches created without a name get a unique generated
+// one.
+func WithName[K comparable, V any](name string) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.name = name
+	}
+}
+
+// WithTombstones makes Remove leave a tombstone for the removed key that
+// lasts for grace. Tombstones are invisible to reads but are included in
+// Export, and Import skips entries for keys it holds a tombstone for, so a
+// lagging replica can't resurrect a deleted key with a stale value.
+func WithTombstones[K comparable, V any](grace time.Duration) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.tombstoneGrace = grace
+	}
+}
+
+// WithTTLGranularity rounds the expiry of every stored item up to the next
+// multiple of d, so items written around the same time share an expiry and
+// are swept together. Rounding can extend an item's life by up to d.
+func WithTTLGranularity[K comparable, V any](d time.Duration) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.granularity = d
+	}
+}
+
+// WithInitialCapacity preallocates room for n items, avoiding repeated map
+// growth while the cache is first filled. Unlike WithCapacity, it doesn't
+// bound the number of items.
+func WithInitialCapacity[K comparable, V any](n int) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.items = make(map[K]item[V], n)
+	}
+}
+
+// WithExpiryFunc sets a function deciding whether an item has expired on
+// top of its TTL, e.g. because the configuration version it was derived
+// from changed. It receives the key, the value and the time the value was
+// stored, and reporting true expires the item like a TTL would, on reads
+// and in cleanup passes. It runs under the lock on every liveness check and
+// must not call back into the cache.
+func WithExpiryFunc[K comparable, V any](expired func(key K, value V, setAt time.Time) bool) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.expiryFunc = expired
+	}
+}
+
+// WithHotSliding makes reads slide the expiration of hot items only: once
+// an item has been read minHits times within window, each further read in
+// the window resets its expiry to its TTL from now. Items read less often
+// expire on their original schedule, so cold items touched now and then
+// don't stay cached forever. The window starts with the first read after
+// the previous one has elapsed.
+func WithHotSliding[K comparable, V any](window time.Duration, minHits int) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.hotWindow = window
+		c.hotMinHits = minHits
+	}
+}
+
+// WithMaxRenewals caps the number of times reads may extend an item's life
+// to n, putting a hard ceiling on how long an item that keeps being read
+// stays cached. Each restart of the idle timeout set with SetWithIdle, each
+// slide of WithHotSliding and each extension by GetAndMaybeExtend counts as
+// a renewal. Once its renewals are used up, the item expires at its current
+// deadline however often it is read. Storing the key anew resets the count.
+func WithMaxRenewals[K comparable, V any](n int) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.maxRenewals = n
+	}
+}
+
+// WithCapacity bounds the cache to at most n items. When a new key is stored
+// in a full cache, the eviction policy picks an item to remove first. The
+// policy defaults to RandomEviction without sampling.
+func WithCapacity[K comparable, V any](n int) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.capacity = n
+	}
+}
+
+// OverflowPolicy determines what happens when a new key is stored in a cache
+// at capacity and the eviction policy has nothing left to evict, because all
+// the items were stored with SetSticky.
+type OverflowPolicy int
+
+const (
+	// OverflowGrow stores the item anyway, letting the cache grow beyond
+	// its capacity. This is the default.
+	OverflowGrow OverflowPolicy = iota
+
+	// OverflowReject drops the write: TrySet, SetChecked and Add report
+	// ErrFull, while the other write methods store nothing and report
+	// failure where they can, e.g. Move and Update returning false.
+	OverflowReject
+
+	// OverflowEvictSticky evicts the sticky item expiring first to make
+	// room.
+	OverflowEvictSticky
+)
+
+// WithOverflowPolicy sets how a cache bounded by WithCapacity behaves when it
+// has nothing left to evict.
+func WithOverflowPolicy[K comparable, V any](policy OverflowPolicy) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.overflow = policy
+	}
+}
+
+// WithRandSource makes the randomized behavior of the cache, currently the
+// choice of victims by RandomEviction, draw from src instead of the global,
+// randomly seeded source, so tests can make it reproducible with a fixed
+// seed. The source is only used with the write lock held, so it needs no
+// synchronization of its own, but it mustn't be shared with other caches.
+func WithRandSource[K comparable, V any](src rand.Source) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.rng = rand.New(src)
+	}
+}
+
+// WithEvictionPolicy sets the policy choosing which item to evict from a
+// cache bounded by WithCapacity.
+func WithEvictionPolicy[K comparable, V any](policy EvictionPolicy[K]) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.policy = policy
+	}
+}
+
+// WithSetValidator sets a function rejecting invalid values, such as nil
+// pointers, before they are stored. It is consulted by SetChecked, Add and
+// Replace, which return its error and store nothing if it fails. Set and the
+// other methods that don't return an error skip it, keeping them fast. It
+// runs outside the lock.
+func WithSetValidator[K comparable, V any](validate func(key K, value V) error) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.validate = validate
+	}
+}
+
+// WithEquals sets the function used by CompareAndSwap and CompareAndDelete
+// to compare values, allowing them on types such as slices and maps that
+// can't be compared with ==. The function runs under the write lock, so it
+// must be pure and fast.
+func WithEquals[K comparable, V any](equals func(a, b V) bool) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.equals = equals
+	}
+}
+
+// WithGetCloner sets a function copying the stored values returned by the
+// read methods, so callers can mutate what they receive without affecting
+// the stored value. These are Get and the methods built on it, MustGet,
+// GetWithSource, GetWithContext and Load, as well as GetVersioned,
+// GetOrdered, GetAndMaybeExtend, GetAndAdjustTTL, GetOrSetWithStatus,
+// GetOrSetMany, GetOrLoadNoCache, Peek, TryGet, Inspect, LoadOrStore, Pop,
+// PopWithExpiry, Items, Stream and EntriesByExpiry. Values returned by Swap,
+// GetAndReset, LoadAndDelete and Export, passed to callbacks or carried by
+// events are not cloned. The cloner only runs on values actually returned,
+// never on stored items.
+func WithGetCloner[K comparable, V any](cloner func(V) V) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.cloner = cloner
+	}
+}
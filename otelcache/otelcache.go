@@ -0,0 +1,128 @@
+// Package otelcache instruments a go-cache Cache with OpenTelemetry. It
+// traces every loader call and records hit, miss and load duration metrics.
+// It lives in its own module, so the cache itself doesn't depend on
+// OpenTelemetry.
+package otelcache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+
+	cache "github.com/abenk-oss/go-cache"
+)
+
+// instrumentationName identifies this package to tracers and meters.
+const instrumentationName = "github.com/abenk-oss/go-cache/otelcache"
+
+type config[K comparable] struct {
+	tracer    trace.Tracer
+	meter     metric.Meter
+	keyString func(key K) string
+}
+
+// Option configures the instrumentation set up by Instrument.
+type Option[K comparable] func(*config[K])
+
+// WithTracer sets the tracer recording a span around each loader call.
+// Without it, no spans are recorded.
+func WithTracer[K comparable](tracer trace.Tracer) Option[K] {
+	return func(c *config[K]) {
+		c.tracer = tracer
+	}
+}
+
+// WithMeter sets the meter creating the hit, miss and load duration
+// instruments. Without it, no metrics are recorded.
+func WithMeter[K comparable](meter metric.Meter) Option[K] {
+	return func(c *config[K]) {
+		c.meter = meter
+	}
+}
+
+// WithKeyString sets the function turning a key into the cache.key span
+// attribute. Returning the empty string omits the attribute, which keeps
+// high-cardinality or sensitive keys out of traces. It defaults to
+// fmt.Sprint.
+func WithKeyString[K comparable](keyString func(key K) string) Option[K] {
+	return func(c *config[K]) {
+		c.keyString = keyString
+	}
+}
+
+// Instrument returns the cache options installing loader, wrapped in a span
+// named "cache.load", and recording the following metrics:
+//
+//   - cache.hits and cache.misses, counting the reads made through Get;
+//   - cache.load.duration, the duration of each loader call in seconds.
+//
+// Spans and metrics carry the name of the cache as the cache.name attribute.
+// Since the loader receives no context, its spans are roots.
+func Instrument[K comparable, V any](loader func(key K) (V, time.Duration, error), opts ...Option[K]) []cache.Option[K, V] {
+
+	cfg := config[K]{
+		tracer:    tracenoop.NewTracerProvider().Tracer(instrumentationName),
+		meter:     metricnoop.NewMeterProvider().Meter(instrumentationName),
+		keyString: func(key K) string { return fmt.Sprint(key) },
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	// The instruments are created eagerly, so a misconfigured meter fails
+	// here rather than on the first read. Errors leave a no-op instrument.
+	hits, _ := cfg.meter.Int64Counter("cache.hits",
+		metric.WithDescription("Number of reads served from the cache."))
+	misses, _ := cfg.meter.Int64Counter("cache.misses",
+		metric.WithDescription("Number of reads missing the cache."))
+	duration, _ := cfg.meter.Float64Histogram("cache.load.duration",
+		metric.WithDescription("Duration of loader calls."),
+		metric.WithUnit("s"))
+
+	var c *cache.Cache[K, V]
+	name := func() attribute.KeyValue {
+		return attribute.String("cache.name", c.Name())
+	}
+
+	return []cache.Option[K, V]{
+		func(cc *cache.Cache[K, V]) {
+			c = cc
+		},
+		cache.WithReadObserver[K, V](func(key K, hit bool) {
+			set := metric.WithAttributes(name())
+			if hit {
+				hits.Add(context.Background(), 1, set)
+			} else {
+				misses.Add(context.Background(), 1, set)
+			}
+		}),
+		cache.WithLoader(func(key K) (V, time.Duration, error) {
+
+			attrs := []attribute.KeyValue{name()}
+			if k := cfg.keyString(key); k != "" {
+				attrs = append(attrs, attribute.String("cache.key", k))
+			}
+
+			ctx, span := cfg.tracer.Start(context.Background(), "cache.load", trace.WithAttributes(attrs...))
+			defer span.End()
+
+			start := time.Now()
+			value, ttl, err := loader(key)
+			duration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(name()))
+
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+
+			return value, ttl, err
+		}),
+	}
+}
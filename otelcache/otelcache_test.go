@@ -0,0 +1,124 @@
+package otelcache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	cache "github.com/abenk-oss/go-cache"
+)
+
+func TestInstrument(t *testing.T) {
+
+	t.Parallel()
+
+	spans := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)).Tracer("test")
+
+	reader := sdkmetric.NewManualReader()
+	meter := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")
+
+	loader := func(key string) (int, time.Duration, error) {
+		if key == "bad" {
+			return 0, 0, errors.New("backend unavailable")
+		}
+		return len(key), 5 * time.Second, nil
+	}
+
+	c := cache.New(1*time.Second, append(
+		Instrument(loader, WithTracer[string](tracer), WithMeter[string](meter)),
+		cache.WithName[string, int]("users"),
+	)...)
+
+	c.Get("key1")
+	c.Get("key1")
+	c.Get("bad")
+
+	ended := spans.Ended()
+	if len(ended) != 2 {
+		t.Fatalf("expected 2 spans, but got %d", len(ended))
+	}
+	if attrs := ended[0].Attributes(); !hasAttribute(attrs, attribute.String("cache.key", "key1")) || !hasAttribute(attrs, attribute.String("cache.name", "users")) {
+		t.Fatalf("expected key and name attributes, but got %v", attrs)
+	}
+	if status := ended[1].Status(); status.Code != codes.Error {
+		t.Fatalf("expected an error status, but got %v", status)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	counts := make(map[string]int64)
+	var loads uint64
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				for _, dp := range data.DataPoints {
+					counts[m.Name] += dp.Value
+				}
+			case metricdata.Histogram[float64]:
+				for _, dp := range data.DataPoints {
+					loads += dp.Count
+				}
+			}
+		}
+	}
+
+	if counts["cache.hits"] != 1 || counts["cache.misses"] != 2 || loads != 2 {
+		t.Fatalf("expected 1 hit, 2 misses and 2 loads, but got %v and %d", counts, loads)
+	}
+}
+
+func TestInstrumentKeyString(t *testing.T) {
+
+	t.Parallel()
+
+	spans := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)).Tracer("test")
+
+	loader := func(key string) (int, time.Duration, error) {
+		return len(key), 5 * time.Second, nil
+	}
+
+	// should omit the key attribute when the hook returns the empty string.
+	c := cache.New(1*time.Second, Instrument(loader,
+		WithTracer[string](tracer),
+		WithKeyString(func(key string) string { return "" }),
+	)...)
+
+	c.Get("secret")
+
+	for _, attr := range spans.Ended()[0].Attributes() {
+		if attr.Key == "cache.key" {
+			t.Fatalf("expected no cache.key attribute, but got %v", attr)
+		}
+	}
+
+	// should work without a tracer or meter.
+	plain := cache.New(1*time.Second, Instrument(loader)...)
+	if value, found := plain.Get("key1"); !found || value != 4 {
+		t.Fatalf("expected 4, but got %v, found: %v", value, found)
+	}
+}
+
+func hasAttribute(attrs []attribute.KeyValue, want attribute.KeyValue) bool {
+
+	for _, attr := range attrs {
+		if attr == want {
+			return true
+		}
+	}
+
+	return false
+}
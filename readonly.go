@@ -0,0 +1,40 @@
+package cache
+
+// ReadOnlyCache is a view of a cache that only exposes read operations. It
+// shares the storage and lock of the cache it was obtained from, so writes
+// made through the cache are visible through the view.
+type ReadOnlyCache[K comparable, V any] struct {
+	c *Cache[K, V]
+}
+
+// ReadOnly returns a read-only view of the cache, for handing to components
+// that must not mutate it.
+func (c *Cache[K, V]) ReadOnly() ReadOnlyCache[K, V] {
+	return ReadOnlyCache[K, V]{c: c}
+}
+
+// Get retrieves the value stored under key, like Cache.Get.
+func (r ReadOnlyCache[K, V]) Get(key K) (V, bool) {
+	return r.c.Get(key)
+}
+
+// Has reports whether a live item is stored under key, like Cache.Has.
+func (r ReadOnlyCache[K, V]) Has(key K) bool {
+	return r.c.Has(key)
+}
+
+// Peek retrieves the value stored under key without affecting its
+// statistics, like Cache.Peek.
+func (r ReadOnlyCache[K, V]) Peek(key K) (V, bool) {
+	return r.c.Peek(key)
+}
+
+// Len returns the number of live items, like Cache.Len.
+func (r ReadOnlyCache[K, V]) Len() int {
+	return r.c.Len()
+}
+
+// Keys returns the keys of all live items, like Cache.Keys.
+func (r ReadOnlyCache[K, V]) Keys() []K {
+	return r.c.Keys()
+}
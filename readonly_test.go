@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestReadOnly(t *testing.T) {
+
+	t.Parallel()
+
+	c := New[string, int](1 * time.Second)
+	view := c.ReadOnly()
+
+	c.Set("a", 1, 5*time.Second)
+	c.Set("b", 2, 50*time.Millisecond)
+
+	if value, found := view.Get("a"); !found || value != 1 {
+		t.Fatalf("expected 1, but got %v, found: %v", value, found)
+	}
+	if value, found := view.Peek("b"); !found || value != 2 {
+		t.Fatalf("expected 2, but got %v, found: %v", value, found)
+	}
+	if !view.Has("a") || view.Has("c") {
+		t.Fatal("expected only a and b to be present")
+	}
+	if n := view.Len(); n != 2 {
+		t.Fatalf("expected 2 items, but got %d", n)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	// should see the expiry of b through the view.
+	if view.Has("b") {
+		t.Fatal("expected b to be expired")
+	}
+	if keys := view.Keys(); !slices.Equal(keys, []string{"a"}) {
+		t.Fatalf("expected [a], but got %v", keys)
+	}
+
+	// should see writes made through the cache.
+	c.Remove("a")
+
+	if n := view.Len(); n != 0 {
+		t.Fatalf("expected 0 items, but got %d", n)
+	}
+}
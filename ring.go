@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Ring routes keys across several caches with consistent hashing, so adding
+// or removing a cache only remaps the keys that hashed to its share of the
+// ring. Each cache is placed on the ring at points derived from its name,
+// which must therefore be unique among the caches of a ring.
+type Ring[K comparable, V any] struct {
+	mu       sync.RWMutex
+	replicas int
+	points   []uint64
+	nodes    map[uint64]*Cache[K, V]
+}
+
+// NewRing returns a ring over caches, placing each cache at replicas points
+// to even out the distribution of keys. Keys are hashed from their
+// fmt.Sprint representation.
+func NewRing[K comparable, V any](replicas int, caches ...*Cache[K, V]) *Ring[K, V] {
+
+	r := &Ring[K, V]{
+		replicas: max(replicas, 1),
+		nodes:    make(map[uint64]*Cache[K, V]),
+	}
+
+	for _, c := range caches {
+		r.AddNode(c)
+	}
+
+	return r
+}
+
+// AddNode places c on the ring.
+func (r *Ring[K, V]) AddNode(c *Cache[K, V]) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for n := 0; n < r.replicas; n++ {
+		point := hashString(c.Name() + "#" + strconv.Itoa(n))
+		if _, taken := r.nodes[point]; taken {
+			continue
+		}
+		r.nodes[point] = c
+		r.points = append(r.points, point)
+	}
+
+	slices.Sort(r.points)
+}
+
+// RemoveNode takes c off the ring. Its items are left in place.
+func (r *Ring[K, V]) RemoveNode(c *Cache[K, V]) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.points = slices.DeleteFunc(r.points, func(point uint64) bool {
+		if r.nodes[point] == c {
+			delete(r.nodes, point)
+			return true
+		}
+		return false
+	})
+}
+
+// Node returns the cache responsible for key, or nil if the ring is empty.
+func (r *Ring[K, V]) Node(key K) *Cache[K, V] {
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.points) == 0 {
+		return nil
+	}
+
+	n, _ := slices.BinarySearch(r.points, hashString(fmt.Sprint(key)))
+	if n == len(r.points) {
+		n = 0
+	}
+
+	return r.nodes[r.points[n]]
+}
+
+// Get retrieves the value stored under key from the cache responsible for it.
+func (r *Ring[K, V]) Get(key K) (V, bool) {
+
+	if c := r.Node(key); c != nil {
+		return c.Get(key)
+	}
+
+	var zero V
+	return zero, false
+}
+
+// Set inserts an item into the cache responsible for key.
+func (r *Ring[K, V]) Set(key K, data V, ttl time.Duration) {
+
+	if c := r.Node(key); c != nil {
+		c.Set(key, data, ttl)
+	}
+}
+
+// Remove removes the item stored under key from the cache responsible for it.
+func (r *Ring[K, V]) Remove(key K) {
+
+	if c := r.Node(key); c != nil {
+		c.Remove(key)
+	}
+}
+
+// hashString hashes s with FNV-1a, followed by a SplitMix64 finalizer to
+// spread similar strings, such as the points of one node, across the ring.
+func hashString(s string) uint64 {
+
+	h := fnv.New64a()
+	h.Write([]byte(s))
+
+	x := h.Sum64()
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRing(t *testing.T) {
+
+	t.Parallel()
+
+	nodes := make([]*Cache[string, int], 4)
+	for n := range nodes {
+		nodes[n] = New(1*time.Second, WithName[string, int](fmt.Sprintf("node%d", n)))
+	}
+
+	r := NewRing(100, nodes[:3]...)
+
+	owners := make(map[string]*Cache[string, int])
+	counts := make(map[*Cache[string, int]]int)
+
+	for i := 0; i < 3000; i++ {
+		key := fmt.Sprintf("key%d", i)
+		r.Set(key, i, 5*time.Second)
+		owners[key] = r.Node(key)
+		counts[owners[key]]++
+	}
+
+	// should spread keys roughly evenly across the nodes.
+	for _, node := range nodes[:3] {
+		if counts[node] < 600 || counts[node] > 1400 {
+			t.Fatalf("expected about 1000 keys on %s, but got %d", node.Name(), counts[node])
+		}
+	}
+
+	if value, found := r.Get("key42"); !found || value != 42 {
+		t.Fatalf("expected 42, but got %v, found: %v", value, found)
+	}
+
+	// should only remap keys to the new node.
+	r.AddNode(nodes[3])
+
+	moved := 0
+	for key, owner := range owners {
+		if node := r.Node(key); node != owner {
+			if node != nodes[3] {
+				t.Fatalf("expected %s to move to the new node, but it moved to %s", key, node.Name())
+			}
+			moved++
+		}
+	}
+	if moved < 400 || moved > 1100 {
+		t.Fatalf("expected about a quarter of the keys to move, but %d did", moved)
+	}
+
+	// should restore the original placement.
+	r.RemoveNode(nodes[3])
+
+	for key, owner := range owners {
+		if node := r.Node(key); node != owner {
+			t.Fatalf("expected %s to return to %s, but got %s", key, owner.Name(), node.Name())
+		}
+	}
+
+	r.Remove("key42")
+	if _, found := r.Get("key42"); found {
+		t.Fatal("expected key42 to be removed")
+	}
+}
@@ -0,0 +1,323 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+)
+
+// SnapshotVersion is the version of the snapshot format written by Export.
+const SnapshotVersion = 1
+
+// Snapshot is a portable copy of the live items of a cache. Expiries are
+// kept as remaining durations rather than absolute times, so a snapshot can
+// be moved to another process and imported without clock skew pushing the
+// expiries back or forth by the time spent in transit.
+type Snapshot[K comparable, V any] struct {
+	Version    int                    `json:"version"`
+	Entries    []SnapshotEntry[K, V]  `json:"entries"`
+	Tombstones []SnapshotTombstone[K] `json:"tombstones,omitempty"`
+}
+
+// SnapshotEntry is a single item of a Snapshot. Items that never expire
+// have a TTL of math.MaxInt64.
+type SnapshotEntry[K comparable, V any] struct {
+	Key   K             `json:"key"`
+	Value V             `json:"value"`
+	TTL   time.Duration `json:"ttl"`
+}
+
+// SnapshotTombstone records a key removed while tombstones were enabled.
+type SnapshotTombstone[K comparable] struct {
+	Key K             `json:"key"`
+	TTL time.Duration `json:"ttl"`
+}
+
+// Export returns a snapshot of the live items and tombstones with their
+// remaining TTLs, ordered by key when a comparator is configured with
+// WithKeyComparator.
+func (c *Cache[K, V]) Export() Snapshot[K, V] {
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	s := Snapshot[K, V]{
+		Version: SnapshotVersion,
+		Entries: make([]SnapshotEntry[K, V], 0, len(c.items)),
+	}
+	now := Now()
+
+	for key, i := range c.items {
+		if e, live := c.entry(key, i, now); live {
+			s.Entries = append(s.Entries, e)
+		}
+	}
+
+	for key, expiry := range c.tombstones {
+		if remaining := expiry.Sub(now); remaining > 0 {
+			s.Tombstones = append(s.Tombstones, SnapshotTombstone[K]{
+				Key: key,
+				TTL: remaining,
+			})
+		}
+	}
+
+	if c.compareKeys != nil {
+		slices.SortFunc(s.Entries, func(a, b SnapshotEntry[K, V]) int {
+			return c.compareKeys(a.Key, b.Key)
+		})
+		slices.SortFunc(s.Tombstones, func(a, b SnapshotTombstone[K]) int {
+			return c.compareKeys(a.Key, b.Key)
+		})
+	}
+
+	return s
+}
+
+// Items returns a copy of the live items as a map from key to value. Unlike
+// Export, it carries no expiries; it suits comparing states with Diff.
+func (c *Cache[K, V]) Items() map[K]V {
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	items := make(map[K]V, len(c.items))
+	for key, i := range c.items {
+		if !c.expired(key, i) {
+			items[key] = c.clone(i.value)
+		}
+	}
+
+	return items
+}
+
+// Diff compares two states of a cache, such as returned by Items at two
+// points in time. It returns the items only found in after, those only found
+// in before, and, for the keys found in both with different values, the
+// value before and after the change.
+func Diff[K, V comparable](before, after map[K]V) (added, removed map[K]V, changed map[K][2]V) {
+	return DiffFunc(before, after, func(a, b V) bool { return a == b })
+}
+
+// DiffFunc is like Diff, but compares values with equal, allowing types such
+// as slices and maps that can't be compared with ==.
+func DiffFunc[K comparable, V any](before, after map[K]V, equal func(a, b V) bool) (added, removed map[K]V, changed map[K][2]V) {
+
+	added = make(map[K]V)
+	removed = make(map[K]V)
+	changed = make(map[K][2]V)
+
+	for key, old := range before {
+		value, found := after[key]
+		switch {
+		case !found:
+			removed[key] = old
+		case !equal(old, value):
+			changed[key] = [2]V{old, value}
+		}
+	}
+
+	for key, value := range after {
+		if _, found := before[key]; !found {
+			added[key] = value
+		}
+	}
+
+	return added, removed, changed
+}
+
+// SaveKeys writes a snapshot of the live items stored under keys to w as
+// JSON, in the order of keys. Missing and expired keys are skipped, as are
+// repeated ones, so the number of items saved is the number of entries of
+// the snapshot read back. Tombstones are not included. The snapshot can be
+// decoded into a Snapshot and passed to Import.
+func (c *Cache[K, V]) SaveKeys(w io.Writer, keys []K) error {
+
+	c.mu.RLock()
+
+	s := Snapshot[K, V]{
+		Version: SnapshotVersion,
+		Entries: make([]SnapshotEntry[K, V], 0, len(keys)),
+	}
+	saved := make(map[K]struct{}, len(keys))
+	now := Now()
+
+	for _, key := range keys {
+		if _, dup := saved[key]; dup {
+			continue
+		}
+		if i, found := c.items[key]; found {
+			if e, live := c.entry(key, i, now); live {
+				s.Entries = append(s.Entries, e)
+				saved[key] = struct{}{}
+			}
+		}
+	}
+
+	c.mu.RUnlock()
+
+	return json.NewEncoder(w).Encode(s)
+}
+
+// entry returns the snapshot entry for the item i stored under key, with
+// the TTL remaining at now, reporting false if the item is no longer live.
+func (c *Cache[K, V]) entry(key K, i item[V], now time.Time) (SnapshotEntry[K, V], bool) {
+
+	remaining := i.remaining(now)
+	if remaining <= 0 || c.expired(key, i) {
+		return SnapshotEntry[K, V]{}, false
+	}
+
+	return SnapshotEntry[K, V]{Key: key, Value: i.value, TTL: remaining}, true
+}
+
+// Import stores the entries of s, each expiring after its remaining TTL as
+// measured from the time of the import. Entries whose TTL is not positive
+// are skipped. Existing items with the same keys are replaced. Tombstones in
+// s delete the matching items and, with WithTombstones, are kept locally;
+// entries for tombstoned keys are skipped. It returns an error if s was
+// written in a newer, unsupported format.
+func (c *Cache[K, V]) Import(s Snapshot[K, V]) error {
+
+	if s.Version < 1 || s.Version > SnapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d", s.Version)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if blocked, err := c.writeErr(); blocked {
+		return err
+	}
+
+	now := Now()
+
+	for _, t := range s.Tombstones {
+		c.delete(t.Key)
+		if t.TTL > 0 && c.tombstoneGrace > 0 {
+			c.bury(t.Key, now.Add(t.TTL))
+		}
+	}
+
+	for _, e := range s.Entries {
+		if expiry, buried := c.tombstones[e.Key]; buried && now.Before(expiry) {
+			continue
+		}
+		if e.TTL > 0 {
+			c.set(e.Key, e.Value, e.TTL)
+		}
+	}
+
+	return nil
+}
+
+// streamChunk is the number of keys Stream looks up per lock acquisition.
+const streamChunk = 256
+
+// Entry is a live item yielded by Stream or returned by EntriesByExpiry. The
+// zero Expiry means the item never expires.
+type Entry[K comparable, V any] struct {
+	Key    K
+	Value  V
+	Expiry time.Time
+}
+
+// Stream yields the live items of the cache on the returned channel, which
+// is closed once all items have been sent or ctx is done. It copies the keys
+// up front and then looks items up in small chunks, so the lock is only held
+// briefly and only one chunk of values is held at a time, unlike Export.
+// Items written after the call are not yielded, and items removed or expired
+// before their chunk is reached are skipped.
+func (c *Cache[K, V]) Stream(ctx context.Context) <-chan Entry[K, V] {
+
+	c.mu.RLock()
+	keys := make([]K, 0, len(c.items))
+	for key := range c.items {
+		keys = append(keys, key)
+	}
+	c.mu.RUnlock()
+
+	if c.compareKeys != nil {
+		slices.SortFunc(keys, c.compareKeys)
+	}
+
+	ch := make(chan Entry[K, V])
+
+	go func() {
+		defer close(ch)
+
+		chunk := make([]Entry[K, V], 0, streamChunk)
+		for len(keys) > 0 {
+			n := min(len(keys), streamChunk)
+
+			chunk = chunk[:0]
+			c.mu.RLock()
+			for _, key := range keys[:n] {
+				if i, found := c.items[key]; found && !c.expired(key, i) {
+					chunk = append(chunk, Entry[K, V]{
+						Key:    key,
+						Value:  c.clone(i.value),
+						Expiry: i.deadline(),
+					})
+				}
+			}
+			c.mu.RUnlock()
+			keys = keys[n:]
+
+			for _, e := range chunk {
+				if ctx.Err() != nil {
+					return
+				}
+				select {
+				case ch <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return ch
+}
+
+// EntriesByExpiry returns the live items ordered by ascending expiry, so the
+// items about to expire come first. Items that never expire come last, and
+// items sharing an expiry are ordered by key when a comparator is configured
+// with WithKeyComparator.
+func (c *Cache[K, V]) EntriesByExpiry() []Entry[K, V] {
+
+	c.mu.RLock()
+
+	entries := make([]Entry[K, V], 0, len(c.items))
+	for key, i := range c.items {
+		if !c.expired(key, i) {
+			entries = append(entries, Entry[K, V]{
+				Key:    key,
+				Value:  c.clone(i.value),
+				Expiry: i.deadline(),
+			})
+		}
+	}
+
+	c.mu.RUnlock()
+
+	slices.SortFunc(entries, func(a, b Entry[K, V]) int {
+		switch {
+		case a.Expiry.Equal(b.Expiry):
+			if c.compareKeys != nil {
+				return c.compareKeys(a.Key, b.Key)
+			}
+			return 0
+		case a.Expiry.IsZero():
+			return 1
+		case b.Expiry.IsZero():
+			return -1
+		}
+		return a.Expiry.Compare(b.Expiry)
+	})
+
+	return entries
+}
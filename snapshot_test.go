@@ -0,0 +1,257 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheExportImport(t *testing.T) {
+
+	t.Parallel()
+
+	src := New[string, int](1 * time.Second)
+
+	src.Set("key1", 10, 5*time.Second)
+	src.Set("key2", 20, 200*time.Millisecond)
+	src.Set("key3", 30, 0*time.Second)
+
+	data, err := json.Marshal(src.Export())
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	var s Snapshot[string, int]
+	if err := json.Unmarshal(data, &s); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	if len(s.Entries) != 2 {
+		t.Fatalf("expected 2 exported entries, but got %d", len(s.Entries))
+	}
+
+	// should skip the entry that expires before the import.
+	for n := range s.Entries {
+		if s.Entries[n].Key == "key2" {
+			s.Entries[n].TTL = 0
+		}
+	}
+
+	dst := New[string, int](1 * time.Second)
+	if err := dst.Import(s); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	if value, found := dst.Get("key1"); !found || value != 10 {
+		t.Fatalf("expected 10, but got %v, found: %v", value, found)
+	}
+	if _, found := dst.Get("key2"); found {
+		t.Fatal("expected entry without remaining TTL to be skipped")
+	}
+
+	s.Version = SnapshotVersion + 1
+	if err := dst.Import(s); err == nil {
+		t.Fatal("expected error for unsupported version, but got none")
+	}
+}
+
+func TestCacheSaveKeys(t *testing.T) {
+
+	t.Parallel()
+
+	src := New[string, int](1*time.Second, WithManualCleanup[string, int]())
+
+	src.Set("key1", 10, 5*time.Second)
+	src.Set("key2", 20, 5*time.Second)
+	src.Set("key3", 30, 5*time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+
+	var buf bytes.Buffer
+	if err := src.SaveKeys(&buf, []string{"key2", "missing", "key3", "key1", "key2"}); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	var s Snapshot[string, int]
+	if err := json.NewDecoder(&buf).Decode(&s); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	// should save the live requested keys once each, in the requested order.
+	if len(s.Entries) != 2 || s.Entries[0].Key != "key2" || s.Entries[1].Key != "key1" {
+		t.Fatalf("expected key2 and key1, but got %+v", s.Entries)
+	}
+	if s.Entries[0].TTL <= 0 || s.Entries[0].TTL > 5*time.Second {
+		t.Fatalf("expected a remaining TTL within 5s, but got %v", s.Entries[0].TTL)
+	}
+
+	dst := New[string, int](1 * time.Second)
+	if err := dst.Import(s); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	if value, found := dst.Get("key2"); !found || value != 20 {
+		t.Fatalf("expected 20, but got %v, found: %v", value, found)
+	}
+}
+
+func TestCacheTombstones(t *testing.T) {
+
+	t.Parallel()
+
+	c := New(1*time.Second, WithTombstones[string, int](5*time.Second))
+
+	c.Set("key1", 10, 5*time.Second)
+	c.Remove("key1")
+
+	if _, found := c.Get("key1"); found {
+		t.Fatal("expected removed item to be missing")
+	}
+
+	s := c.Export()
+	if len(s.Tombstones) != 1 || s.Tombstones[0].Key != "key1" {
+		t.Fatalf("expected a tombstone for key1, but got %+v", s.Tombstones)
+	}
+
+	// should not be resurrected by a stale replica.
+	stale := Snapshot[string, int]{
+		Version: SnapshotVersion,
+		Entries: []SnapshotEntry[string, int]{{Key: "key1", Value: 10, TTL: 5 * time.Second}},
+	}
+	if err := c.Import(stale); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if _, found := c.Get("key1"); found {
+		t.Fatal("expected tombstoned key to stay deleted")
+	}
+
+	// should propagate the delete to a replica.
+	replica := New(1*time.Second, WithTombstones[string, int](5*time.Second))
+	replica.Set("key1", 10, 5*time.Second)
+
+	if err := replica.Import(s); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if _, found := replica.Get("key1"); found {
+		t.Fatal("expected tombstone to delete the replica's item")
+	}
+
+	// should clear the tombstone.
+	c.Set("key1", 20, 5*time.Second)
+	if s := c.Export(); len(s.Tombstones) != 0 {
+		t.Fatalf("expected no tombstones after set, but got %+v", s.Tombstones)
+	}
+}
+
+func TestCacheStream(t *testing.T) {
+
+	t.Parallel()
+
+	c := New[int, int](1 * time.Second)
+
+	for key := range 1000 {
+		c.Set(key, key*10, 5*time.Second)
+	}
+	c.Set(-1, 0, 0*time.Second)
+
+	seen := make(map[int]bool)
+	for e := range c.Stream(context.Background()) {
+		if e.Value != e.Key*10 {
+			t.Fatalf("expected %d, but got %v", e.Key*10, e.Value)
+		}
+		seen[e.Key] = true
+	}
+
+	// should yield every live item once and skip the expired one.
+	if len(seen) != 1000 || seen[-1] {
+		t.Fatalf("expected 1000 live entries, but got %d", len(seen))
+	}
+
+	// should stop early when the context is cancelled.
+	ctx, cancel := context.WithCancel(context.Background())
+
+	ch := c.Stream(ctx)
+	<-ch
+	cancel()
+
+	n := 0
+	for range ch {
+		n++
+	}
+	if n > 1 {
+		t.Fatalf("expected at most 1 entry after cancelling, but got %d", n)
+	}
+}
+
+func TestCacheEntriesByExpiry(t *testing.T) {
+
+	t.Parallel()
+
+	c := New(1*time.Second, WithKeyComparator[string, int](strings.Compare))
+
+	c.Set("key1", 10, 3*time.Second)
+	c.Set("key2", 20, 1*time.Second)
+	c.Set("key4", 40, 2*time.Second)
+	c.Set("key5", 50, 0*time.Second)
+
+	// Items only lack an expiry in a cache created by NewLRU, so one is
+	// planted directly.
+	c.mu.Lock()
+	c.items["key3"] = item[int]{value: 30}
+	c.mu.Unlock()
+
+	keys := []string{}
+	for _, e := range c.EntriesByExpiry() {
+		keys = append(keys, e.Key)
+	}
+
+	// should order by expiry, skipping expired items, with the never
+	// expiring item last.
+	if !slices.Equal(keys, []string{"key2", "key4", "key1", "key3"}) {
+		t.Fatalf("expected [key2 key4 key1 key3], but got %v", keys)
+	}
+}
+
+func TestDiff(t *testing.T) {
+
+	t.Parallel()
+
+	c := New[string, int](1 * time.Second)
+
+	c.Set("key1", 10, 5*time.Second)
+	c.Set("key2", 20, 5*time.Second)
+	c.Set("key3", 30, 5*time.Second)
+
+	before := c.Items()
+
+	c.Remove("key1")
+	c.Set("key2", 21, 5*time.Second)
+	c.Set("key4", 40, 5*time.Second)
+	c.Set("key5", 50, 0*time.Second)
+
+	added, removed, changed := Diff(before, c.Items())
+
+	// should sort every key into one of the three categories, or none.
+	if len(added) != 1 || added["key4"] != 40 {
+		t.Fatalf("expected key4 to be added, but got %v", added)
+	}
+	if len(removed) != 1 || removed["key1"] != 10 {
+		t.Fatalf("expected key1 to be removed, but got %v", removed)
+	}
+	if len(changed) != 1 || changed["key2"] != [2]int{20, 21} {
+		t.Fatalf("expected key2 to change from 20 to 21, but got %v", changed)
+	}
+
+	// should compare non-comparable values with the equality function.
+	_, _, changedSlices := DiffFunc(
+		map[string][]int{"key1": {1, 2}, "key2": {3}},
+		map[string][]int{"key1": {1, 2}, "key2": {4}},
+		slices.Equal[[]int],
+	)
+	if len(changedSlices) != 1 || changedSlices["key2"][1][0] != 4 {
+		t.Fatalf("expected key2 to change, but got %v", changedSlices)
+	}
+}
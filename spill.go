@@ -0,0 +1,71 @@
+package cache
+
+import "time"
+
+// Backend is a slower store, such as a disk or a remote cache, that a cache
+// configured with WithSpill demotes evicted items to. It is called with the
+// cache lock held on eviction, so Put should be fast or buffer its writes.
+// Errors are the backend's to handle: a failed Put loses the item, as a
+// plain eviction would.
+type Backend[K comparable, V any] interface {
+	// Put stores value under key, to expire after ttl.
+	Put(key K, value V, ttl time.Duration)
+
+	// Take removes the value stored under key and returns it along with
+	// its remaining time to live, or false if there is none.
+	Take(key K) (value V, ttl time.Duration, found bool)
+}
+
+// WithSpill makes a cache bounded by WithCapacity demote the live items it
+// evicts to backend instead of dropping them, and makes Get recall them on a
+// miss, before any loader runs. A recalled item is stored again with the TTL
+// it had left, possibly evicting another item to the backend in turn. Items
+// removed or expired otherwise are not spilled.
+func WithSpill[K comparable, V any](backend Backend[K, V]) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.backend = backend
+	}
+}
+
+// spill demotes the item stored under key to the backend, if any, before it
+// is evicted.
+func (c *Cache[K, V]) spill(key K) {
+
+	if c.backend == nil {
+		return
+	}
+
+	if i, found := c.items[key]; found && !c.expired(key, i) {
+		c.backend.Put(key, i.value, i.remaining(Now()))
+	}
+}
+
+// recall takes the item stored under key back from the backend, if any, and
+// stores it again unless another goroutine stored the key meanwhile.
+func (c *Cache[K, V]) recall(key K) (V, bool) {
+
+	if c.backend == nil {
+		var zero V
+		return zero, false
+	}
+
+	value, ttl, found := c.backend.Take(key)
+	if !found || ttl <= 0 {
+		var zero V
+		return zero, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if i, found := c.items[key]; found && !c.expired(key, i) {
+		return i.value, true
+	}
+	if c.closed || c.frozen || !c.set(key, value, ttl) {
+		// The item can't be stored back, so it is returned to the backend
+		// rather than lost.
+		c.backend.Put(key, value, ttl)
+	}
+
+	return value, true
+}
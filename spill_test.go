@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryBackend is a Backend keeping spilled items in a map.
+type memoryBackend[K comparable, V any] struct {
+	mu    sync.Mutex
+	items map[K]V
+}
+
+func (b *memoryBackend[K, V]) Put(key K, value V, ttl time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.items[key] = value
+}
+
+func (b *memoryBackend[K, V]) Take(key K) (V, time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	value, found := b.items[key]
+	delete(b.items, key)
+	return value, time.Minute, found
+}
+
+func TestCacheSpill(t *testing.T) {
+
+	t.Parallel()
+
+	backend := &memoryBackend[string, int]{items: make(map[string]int)}
+
+	c := New(1*time.Second,
+		WithCapacity[string, int](1),
+		WithEvictionPolicy[string, int](NewLRUEviction[string]()),
+		WithSpill[string, int](backend),
+	)
+
+	c.Set("key1", 10, 5*time.Second)
+	c.Set("key2", 20, 5*time.Second)
+
+	// should demote the evicted item to the backend.
+	if value, found := backend.items["key1"]; !found || value != 10 {
+		t.Fatalf("expected key1 to be spilled, but got %v, found: %v", value, found)
+	}
+
+	// should recall the spilled item, demoting key2 in turn.
+	if value, found := c.Get("key1"); !found || value != 10 {
+		t.Fatalf("expected 10, but got %v, found: %v", value, found)
+	}
+	if _, found := backend.items["key2"]; !found {
+		t.Fatal("expected key2 to be spilled")
+	}
+	if _, found := backend.items["key1"]; found {
+		t.Fatal("expected key1 to be taken back from the backend")
+	}
+
+	// should recall through Load and GetWithContext as well.
+	if value, err := c.Load(context.Background(), "key2"); err != nil || value != 20 {
+		t.Fatalf("expected 20, but got %v, err: %v", value, err)
+	}
+	if value, found, err := c.GetWithContext(context.Background(), "key1"); err != nil || !found || value != 10 {
+		t.Fatalf("expected 10, but got %v, found: %v, err: %v", value, found, err)
+	}
+
+	// should not spill removed items.
+	c.Remove("key1")
+	if _, found := c.Get("key1"); found {
+		t.Fatal("expected removed key1 not to be recalled")
+	}
+}
+
+func TestCacheSpillRecallRejected(t *testing.T) {
+
+	t.Parallel()
+
+	backend := &memoryBackend[string, int]{items: map[string]int{"key1": 10}}
+
+	c := New(1*time.Second,
+		WithCapacity[string, int](1),
+		WithOverflowPolicy[string, int](OverflowReject),
+		WithSpill[string, int](backend),
+	)
+	c.SetSticky("key2", 20, 5*time.Second)
+
+	// should hand the recalled item back to the backend if it can't be
+	// stored.
+	if value, found := c.Get("key1"); !found || value != 10 {
+		t.Fatalf("expected 10, but got %v, found: %v", value, found)
+	}
+	if _, found := backend.items["key1"]; !found {
+		t.Fatal("expected key1 to be returned to the backend")
+	}
+}
@@ -0,0 +1,214 @@
+package cache
+
+import (
+	"container/heap"
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a point-in-time view of the counters of a cache.
+type Stats struct {
+	// Name is the name of the cache.
+	Name string
+	// DroppedEvents is the number of events discarded because the events
+	// channel was full.
+	DroppedEvents uint64
+
+	// Gets, Sets and Removes count the reads, writes and removals of single
+	// items since the cache was created, whether or not they found an item.
+	// They are only maintained with WithOpCounting.
+	Gets    uint64
+	Sets    uint64
+	Removes uint64
+}
+
+// counters holds the statistics of a cache. They are updated atomically so
+// reading them never contends with the cache lock.
+type counters struct {
+	droppedEvents atomic.Uint64
+
+	countOps bool
+	gets     atomic.Uint64
+	sets     atomic.Uint64
+	removes  atomic.Uint64
+
+	trackAccess bool
+	lastAccess  atomic.Int64
+}
+
+// WithOpCounting makes the cache count every get, set and remove, reported
+// by Stats. The counters are atomic and don't take the cache lock.
+func WithOpCounting[K comparable, V any]() Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.counters.countOps = true
+	}
+}
+
+// WithAccessTracking makes the cache record the time of the last get, set
+// or remove of any item, reported by LastAccess, e.g. to tear down caches of
+// a pool that have gone unused. The time is stored atomically and doesn't
+// take the cache lock.
+func WithAccessTracking[K comparable, V any]() Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.counters.trackAccess = true
+	}
+}
+
+// count increments the operation counter n if operation counting is enabled
+// and records the access time if access tracking is.
+func (c *counters) count(n *atomic.Uint64) {
+	if c.countOps {
+		n.Add(1)
+	}
+	if c.trackAccess {
+		c.lastAccess.Store(Now().UnixNano())
+	}
+}
+
+// LastAccess returns the time of the last get, set or remove of any item, or
+// the zero time if there has been none or the cache was created without
+// WithAccessTracking.
+func (c *Cache[K, V]) LastAccess() time.Time {
+
+	ns := c.counters.lastAccess.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, ns)
+}
+
+// WithSizeSampler makes a dedicated goroutine call sampler every interval
+// with the number of live items, as reported by Len, until the cache is
+// closed, e.g. for capacity planning. Each sample takes the read lock once,
+// and sampler runs outside the lock. A non-positive interval disables
+// sampling.
+func WithSizeSampler[K comparable, V any](interval time.Duration, sampler func(size int)) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.sampleInterval = interval
+		c.sampler = sampler
+	}
+}
+
+// sampleLoop reports the size of the cache to the size sampler every sample
+// interval until the cache is closed.
+func (c *Cache[K, V]) sampleLoop() {
+
+	ticker := time.NewTicker(c.sampleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+
+		c.sampler(c.Len())
+	}
+}
+
+// Stats returns the current statistics of the cache.
+func (c *Cache[K, V]) Stats() Stats {
+	return Stats{
+		Name:          c.name,
+		DroppedEvents: c.counters.droppedEvents.Load(),
+		Gets:          c.counters.gets.Load(),
+		Sets:          c.counters.sets.Load(),
+		Removes:       c.counters.removes.Load(),
+	}
+}
+
+// ResetStats returns the current statistics like Stats and zeroes the
+// counters, e.g. to compute rates over successive windows. Each counter is
+// read and zeroed in a single atomic swap, so no update is lost between the
+// returned statistics and the next window, although updates racing with the
+// reset may land on either side of it.
+func (c *Cache[K, V]) ResetStats() Stats {
+	return Stats{
+		Name:          c.name,
+		DroppedEvents: c.counters.droppedEvents.Swap(0),
+		Gets:          c.counters.gets.Swap(0),
+		Sets:          c.counters.sets.Swap(0),
+		Removes:       c.counters.removes.Swap(0),
+	}
+}
+
+// KeyStat reports how often a key has been read.
+type KeyStat[K comparable] struct {
+	Key  K
+	Hits uint64
+}
+
+// LeastUsed returns up to n live keys with the fewest successful Get calls
+// since they were last set, in ascending order of hits.
+func (c *Cache[K, V]) LeastUsed(n int) []KeyStat[K] {
+	return c.usage(n, func(a, b uint64) bool { return a < b })
+}
+
+// MostUsed returns up to n live keys with the most successful Get calls
+// since they were last set, in descending order of hits.
+func (c *Cache[K, V]) MostUsed(n int) []KeyStat[K] {
+	return c.usage(n, func(a, b uint64) bool { return a > b })
+}
+
+// usage selects the n keys ranking first by hits according to before. It
+// keeps a heap of the n best candidates rather than sorting every key.
+func (c *Cache[K, V]) usage(n int, before func(a, b uint64) bool) []KeyStat[K] {
+
+	if n <= 0 {
+		return nil
+	}
+
+	// The heap root is the worst of the kept candidates, so it is the one
+	// replaced by a better key.
+	h := &keyStatHeap[K]{less: func(a, b uint64) bool { return before(b, a) }}
+
+	c.mu.RLock()
+
+	for key, i := range c.items {
+		if c.expired(key, i) {
+			continue
+		}
+
+		if h.Len() < n {
+			heap.Push(h, KeyStat[K]{Key: key, Hits: i.hits})
+		} else if before(i.hits, h.stats[0].Hits) {
+			h.stats[0] = KeyStat[K]{Key: key, Hits: i.hits}
+			heap.Fix(h, 0)
+		}
+	}
+
+	c.mu.RUnlock()
+
+	stats := make([]KeyStat[K], h.Len())
+	for k := len(stats) - 1; k >= 0; k-- {
+		stats[k] = heap.Pop(h).(KeyStat[K])
+	}
+
+	return stats
+}
+
+// keyStatHeap implements heap.Interface over key statistics.
+type keyStatHeap[K comparable] struct {
+	stats []KeyStat[K]
+	less  func(a, b uint64) bool
+}
+
+func (h *keyStatHeap[K]) Len() int           { return len(h.stats) }
+func (h *keyStatHeap[K]) Less(a, b int) bool { return h.less(h.stats[a].Hits, h.stats[b].Hits) }
+func (h *keyStatHeap[K]) Swap(a, b int)      { h.stats[a], h.stats[b] = h.stats[b], h.stats[a] }
+func (h *keyStatHeap[K]) Push(x any)         { h.stats = append(h.stats, x.(KeyStat[K])) }
+
+func (h *keyStatHeap[K]) Pop() any {
+	last := h.stats[len(h.stats)-1]
+	h.stats = h.stats[:len(h.stats)-1]
+	return last
+}
+
+// observeRead reports a read of key to the read observer, if any.
+func (c *Cache[K, V]) observeRead(key K, hit bool) {
+	if c.readObserver != nil {
+		c.readObserver(key, hit)
+	}
+}
@@ -0,0 +1,216 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCacheUsage(t *testing.T) {
+
+	t.Parallel()
+
+	c := New[string, int](1 * time.Second)
+
+	// key<n> is read n times.
+	for n := 0; n < 10; n++ {
+		key := fmt.Sprintf("key%d", n)
+		c.Set(key, n, 5*time.Second)
+		for i := 0; i < n; i++ {
+			c.Get(key)
+		}
+	}
+
+	most := c.MostUsed(3)
+	if len(most) != 3 || most[0].Key != "key9" || most[1].Key != "key8" || most[2].Key != "key7" {
+		t.Fatalf("expected key9, key8 and key7, but got %v", most)
+	}
+	if most[0].Hits != 9 {
+		t.Fatalf("expected 9 hits, but got %d", most[0].Hits)
+	}
+
+	least := c.LeastUsed(2)
+	if len(least) != 2 || least[0].Key != "key0" || least[1].Key != "key1" {
+		t.Fatalf("expected key0 and key1, but got %v", least)
+	}
+
+	if all := c.MostUsed(100); len(all) != 10 {
+		t.Fatalf("expected all 10 keys, but got %d", len(all))
+	}
+}
+
+func TestCacheOpCounting(t *testing.T) {
+
+	t.Parallel()
+
+	c := New(1*time.Second, WithOpCounting[string, int]())
+
+	c.Set("key1", 10, 5*time.Second)
+	c.Set("key2", 20, 5*time.Second)
+	c.Get("key1")
+	c.Get("missing")
+	c.Peek("key2")
+	c.Remove("key1")
+
+	stats := c.Stats()
+	if stats.Gets != 3 || stats.Sets != 2 || stats.Removes != 1 {
+		t.Fatalf("expected 3 gets, 2 sets and 1 remove, but got %+v", stats)
+	}
+
+	// should not count without the option.
+	plain := New[string, int](1 * time.Second)
+	plain.Set("key1", 10, 5*time.Second)
+	plain.Get("key1")
+
+	if stats := plain.Stats(); stats.Gets != 0 || stats.Sets != 0 {
+		t.Fatalf("expected no counted operations, but got %+v", stats)
+	}
+}
+
+func TestCacheResetStats(t *testing.T) {
+
+	t.Parallel()
+
+	c := New(1*time.Second, WithOpCounting[string, int]())
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				c.Get("key1")
+			}
+		}()
+	}
+
+	// should lose no counted operation across concurrent resets.
+	var gets uint64
+	for range 20 {
+		gets += c.ResetStats().Gets
+	}
+	wg.Wait()
+	gets += c.ResetStats().Gets
+
+	if gets != 1000 {
+		t.Fatalf("expected 1000 gets across the windows, but got %d", gets)
+	}
+	if stats := c.Stats(); stats.Gets != 0 {
+		t.Fatalf("expected zeroed counters, but got %+v", stats)
+	}
+}
+
+func TestCacheLastAccess(t *testing.T) {
+
+	t.Parallel()
+
+	c := New(1*time.Second, WithAccessTracking[string, int]())
+
+	// should report the zero time before any access.
+	if last := c.LastAccess(); !last.IsZero() {
+		t.Fatalf("expected the zero time, but got %v", last)
+	}
+
+	before := time.Now()
+	c.Set("key1", 10, 5*time.Second)
+
+	set := c.LastAccess()
+	if set.Before(before.Truncate(time.Microsecond)) {
+		t.Fatalf("expected an access after %v, but got %v", before, set)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	c.Get("missing")
+
+	// should record reads, including misses.
+	if last := c.LastAccess(); !last.After(set) {
+		t.Fatalf("expected an access after %v, but got %v", set, last)
+	}
+
+	// should not track without the option.
+	plain := New[string, int](1 * time.Second)
+	plain.Set("key1", 10, 5*time.Second)
+
+	if last := plain.LastAccess(); !last.IsZero() {
+		t.Fatalf("expected the zero time, but got %v", last)
+	}
+}
+
+func TestCacheReadObserver(t *testing.T) {
+
+	t.Parallel()
+
+	var hits, misses int
+
+	c := New(1*time.Second, WithReadObserver[string, int](func(key string, hit bool) {
+		if hit {
+			hits++
+		} else {
+			misses++
+		}
+	}))
+
+	c.Set("key1", 10, 5*time.Second)
+	c.Get("key1")
+	c.Get("key1")
+	c.Get("missing")
+
+	if hits != 2 || misses != 1 {
+		t.Fatalf("expected 2 hits and 1 miss, but got %d and %d", hits, misses)
+	}
+}
+
+func TestCacheSizeSampler(t *testing.T) {
+
+	t.Parallel()
+
+	samples := make(chan int, 100)
+
+	c := New(1*time.Second,
+		WithManualCleanup[string, int](),
+		WithSizeSampler[string, int](10*time.Millisecond, func(size int) {
+			samples <- size
+		}),
+	)
+
+	c.Set("key1", 10, 5*time.Second)
+	c.Set("key2", 20, 5*time.Second)
+	c.Set("key3", 30, 0*time.Second)
+
+	// should report the live size, even with manual cleanup.
+	for {
+		select {
+		case size := <-samples:
+			if size == 2 {
+				c.Close()
+				return
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected a sample of 2 items, but got none")
+		}
+	}
+}
+
+func TestCacheSizeSamplerNonPositiveInterval(t *testing.T) {
+
+	t.Parallel()
+
+	samples := make(chan int, 100)
+
+	c := New(1*time.Second,
+		WithManualCleanup[string, int](),
+		WithSizeSampler[string, int](0, func(size int) {
+			samples <- size
+		}),
+	)
+	defer c.Close()
+
+	c.Set("key1", 10, 5*time.Second)
+	time.Sleep(50 * time.Millisecond)
+
+	// should disable sampling instead of panicking.
+	if n := len(samples); n != 0 {
+		t.Fatalf("expected no sample, but got %d", n)
+	}
+}
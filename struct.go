@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// SetStruct stores every exported field of the struct s, or of the struct s
+// points to, under its field name. Fields of embedded structs are promoted
+// and stored under their own names, as encoding/json does, while other
+// struct fields are stored as whole values. As with encoding/json, a field
+// hides the promoted fields of the same name nested deeper, and fields of
+// the same name at the same depth hide each other. Unexported fields are
+// skipped. All fields are written under a single lock acquisition, and
+// none is if s refers to itself through embedded pointers.
+func SetStruct(c *Cache[string, any], s any, ttl time.Duration) error {
+	return setStruct(c, s, ttl, false)
+}
+
+// SetStructDotted is like SetStruct, but descends into nested struct fields,
+// including non-nil pointers to structs, and stores their fields under
+// dotted paths such as "Database.Host". A pointer cycle is reported as an
+// error, leaving the cache unchanged.
+func SetStructDotted(c *Cache[string, any], s any, ttl time.Duration) error {
+	return setStruct(c, s, ttl, true)
+}
+
+func setStruct(c *Cache[string, any], s any, ttl time.Duration, dotted bool) error {
+
+	v := reflect.ValueOf(s)
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("value of type %T is not a struct", s)
+	}
+
+	fields := make(map[string]structField)
+	if err := structFields(v, "", 0, dotted, fields, make(map[uintptr]bool)); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if blocked, err := c.writeErr(); blocked {
+		return err
+	}
+
+	for key, f := range fields {
+		if !f.ambiguous {
+			c.set(key, f.value, ttl)
+		}
+	}
+
+	return nil
+}
+
+// structField is a field collected by structFields, along with the depth of
+// the struct it was found in.
+type structField struct {
+	value any
+	depth int
+
+	// ambiguous marks a name held by several fields at the same depth.
+	ambiguous bool
+}
+
+// structFields collects the fields of the struct v, found at depth, into
+// fields, keyed by their names prefixed with prefix. A name already held by
+// a shallower field is left alone. path holds the pointers followed to reach
+// v, so that a struct referring to itself is reported instead of recursing
+// forever.
+func structFields(v reflect.Value, prefix string, depth int, dotted bool, fields map[string]structField, path map[uintptr]bool) error {
+
+	t := v.Type()
+	for n := range t.NumField() {
+		f, value := t.Field(n), v.Field(n)
+
+		if f.Anonymous {
+			if ok, err := structNested(value, prefix, depth+1, dotted, fields, path); err != nil {
+				return err
+			} else if ok {
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		if dotted {
+			if ok, err := structNested(value, prefix+f.Name+".", depth+1, dotted, fields, path); err != nil {
+				return err
+			} else if ok {
+				continue
+			}
+		}
+
+		key := prefix + f.Name
+		if old, found := fields[key]; found && old.depth <= depth {
+			if old.depth == depth {
+				old.ambiguous = true
+				fields[key] = old
+			}
+			continue
+		}
+
+		fields[key] = structField{value: value.Interface(), depth: depth}
+	}
+
+	return nil
+}
+
+// structNested collects the fields of the struct v holds or points to, if
+// any, reporting whether it does.
+func structNested(v reflect.Value, prefix string, depth int, dotted bool, fields map[string]structField, path map[uintptr]bool) (bool, error) {
+
+	nested, ok := structValue(v)
+	if !ok {
+		return false, nil
+	}
+
+	if v.Kind() == reflect.Pointer {
+		p := v.Pointer()
+		if path[p] {
+			return true, fmt.Errorf("encountered a cycle via %s", v.Type())
+		}
+		path[p] = true
+		defer delete(path, p)
+	}
+
+	return true, structFields(nested, prefix, depth, dotted, fields, path)
+}
+
+// structValue returns the struct v holds or points to, if any.
+func structValue(v reflect.Value) (reflect.Value, bool) {
+
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return v, false
+		}
+		v = v.Elem()
+	}
+
+	return v, v.Kind() == reflect.Struct
+}
@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+type testDatabase struct {
+	Host string
+	Port int
+}
+
+type testBase struct {
+	Version int
+}
+
+type testConfig struct {
+	testBase
+	Name     string
+	Database testDatabase
+	Replica  *testDatabase
+	Backup   *testDatabase
+	secret   string
+}
+
+func TestSetStruct(t *testing.T) {
+
+	t.Parallel()
+
+	config := testConfig{
+		testBase: testBase{Version: 2},
+		Name:     "api",
+		Database: testDatabase{Host: "db", Port: 5432},
+		Replica:  &testDatabase{Host: "replica", Port: 5433},
+		secret:   "hunter2",
+	}
+
+	c := New[string, any](1 * time.Second)
+
+	if err := SetStruct(c, &config, 5*time.Second); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	// should promote embedded fields and keep nested structs whole.
+	if value, found := c.Get("Version"); !found || value != 2 {
+		t.Fatalf("expected 2, but got %v, found: %v", value, found)
+	}
+	if value, found := c.Get("Database"); !found || value != config.Database {
+		t.Fatalf("expected %v, but got %v, found: %v", config.Database, value, found)
+	}
+	if _, found := c.Get("secret"); found {
+		t.Fatal("expected the unexported field to be skipped")
+	}
+	if keys := c.Keys(); len(keys) != 5 {
+		t.Fatalf("expected 5 keys, but got %v", keys)
+	}
+
+	dotted := New[string, any](1 * time.Second)
+
+	if err := SetStructDotted(dotted, config, 5*time.Second); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	// should descend into nested structs and non-nil pointers.
+	if value, found := dotted.Get("Database.Host"); !found || value != "db" {
+		t.Fatalf("expected db, but got %v, found: %v", value, found)
+	}
+	if value, found := dotted.Get("Replica.Port"); !found || value != 5433 {
+		t.Fatalf("expected 5433, but got %v, found: %v", value, found)
+	}
+	if value, found := dotted.Get("Backup"); !found || value != (*testDatabase)(nil) {
+		t.Fatalf("expected a nil pointer, but got %v, found: %v", value, found)
+	}
+
+	type named struct {
+		Name string
+	}
+	type other struct {
+		Name string
+	}
+	shadowed := New[string, any](1 * time.Second)
+
+	if err := SetStruct(shadowed, struct {
+		named
+		Name string
+	}{named{Name: "embedded"}, "outer"}, 5*time.Second); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	// should give the outer field priority over the promoted one.
+	if value, found := shadowed.Get("Name"); !found || value != "outer" {
+		t.Fatalf("expected outer, but got %v, found: %v", value, found)
+	}
+
+	ambiguous := New[string, any](1 * time.Second)
+
+	if err := SetStruct(ambiguous, struct {
+		named
+		other
+	}{named{Name: "a"}, other{Name: "b"}}, 5*time.Second); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	// should skip promoted fields of the same name at the same depth.
+	if value, found := ambiguous.Get("Name"); found {
+		t.Fatalf("expected the ambiguous field to be skipped, but got %v", value)
+	}
+
+	type node struct {
+		Name string
+		Next *node
+	}
+	loop := &node{Name: "loop"}
+	loop.Next = loop
+
+	cyclic := New[string, any](1 * time.Second)
+
+	// should report a pointer cycle instead of recursing forever.
+	if err := SetStructDotted(cyclic, loop, 5*time.Second); err == nil {
+		t.Fatal("expected an error for a pointer cycle")
+	}
+	if n := cyclic.Len(); n != 0 {
+		t.Fatalf("expected no items, but got %d", n)
+	}
+
+	type chain struct {
+		*chain
+		Name string
+	}
+	self := &chain{Name: "self"}
+	self.chain = self
+
+	if err := SetStruct(cyclic, self, 5*time.Second); err == nil {
+		t.Fatal("expected an error for an embedded pointer cycle")
+	}
+
+	// should accept the same pointer reached through distinct paths.
+	shared := &testDatabase{Host: "db"}
+	if err := SetStructDotted(cyclic, testConfig{Replica: shared, Backup: shared}, 5*time.Second); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	if err := SetStruct(c, 42, 5*time.Second); err == nil {
+		t.Fatal("expected an error for a non-struct value")
+	}
+}
@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"slices"
+	"time"
+)
+
+// SetWithTags inserts an item carrying tags, so it can later be listed with
+// KeysByTag or removed along with the other items sharing a tag with
+// RemoveByTag. Tags last until the item is removed or expires; a later Set
+// keeps them, while SetWithTags replaces them.
+func (c *Cache[K, V]) SetWithTags(key K, data V, ttl time.Duration, tags ...string) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if blocked, _ := c.writeErr(); blocked {
+		return
+	}
+
+	c.set(key, data, ttl)
+
+	if _, found := c.items[key]; found {
+		c.tag(key, slices.Clone(tags))
+	}
+}
+
+// KeysByTag returns the keys of the live items carrying tag, ordered by the
+// comparator configured with WithKeyComparator, if any.
+func (c *Cache[K, V]) KeysByTag(tag string) []K {
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var keys []K
+	for key := range c.tagged[tag] {
+		if i, found := c.items[key]; found && !c.expired(key, i) {
+			keys = append(keys, key)
+		}
+	}
+
+	if c.compareKeys != nil {
+		slices.SortFunc(keys, c.compareKeys)
+	}
+
+	return keys
+}
+
+// RemoveByTag removes all the items carrying tag, like Remove, and returns
+// the number of live items removed. Expired items carrying tag are removed
+// as well but not counted.
+func (c *Cache[K, V]) RemoveByTag(tag string) int {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if blocked, _ := c.writeErr(); blocked {
+		return 0
+	}
+
+	n := 0
+	for key := range c.tagged[tag] {
+		i, found := c.items[key]
+		if !found {
+			continue
+		}
+		if c.expired(key, i) {
+			c.expire(key)
+			continue
+		}
+		c.erase(key)
+		n++
+	}
+
+	return n
+}
@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCacheTags(t *testing.T) {
+
+	t.Parallel()
+
+	c := New(1*time.Second, WithManualCleanup[string, int]())
+
+	c.SetWithTags("key1", 10, 5*time.Second, "user")
+	c.SetWithTags("key2", 20, 5*time.Second, "user", "config")
+	c.SetWithTags("key3", 30, 5*time.Second, "config")
+	c.Set("key4", 40, 5*time.Second)
+
+	keys := c.KeysByTag("user")
+	slices.Sort(keys)
+
+	if !slices.Equal(keys, []string{"key1", "key2"}) {
+		t.Fatalf("expected [key1 key2], but got %v", keys)
+	}
+
+	// should keep the tags on Set and replace them on SetWithTags.
+	c.Set("key1", 11, 5*time.Second)
+	c.SetWithTags("key2", 21, 5*time.Second, "config")
+
+	if keys := c.KeysByTag("user"); !slices.Equal(keys, []string{"key1"}) {
+		t.Fatalf("expected [key1], but got %v", keys)
+	}
+
+	if n := c.RemoveByTag("config"); n != 2 {
+		t.Fatalf("expected 2 removed items, but got %d", n)
+	}
+	if keys := c.Keys(); len(keys) != 2 {
+		t.Fatalf("expected 2 items left, but got %v", keys)
+	}
+	if keys := c.KeysByTag("config"); len(keys) != 0 {
+		t.Fatalf("expected no keys, but got %v", keys)
+	}
+}
+
+func TestCacheTagsExpiry(t *testing.T) {
+
+	t.Parallel()
+
+	c := New(1*time.Second, WithManualCleanup[string, int]())
+
+	c.SetWithTags("key1", 10, 0*time.Second, "user")
+	c.SetWithTags("key2", 20, 5*time.Second, "user")
+
+	// should hide expired items and drop them from the index once removed.
+	if keys := c.KeysByTag("user"); !slices.Equal(keys, []string{"key2"}) {
+		t.Fatalf("expected [key2], but got %v", keys)
+	}
+
+	c.RunCleanup()
+
+	c.mu.RLock()
+	_, indexed := c.tagged["user"]["key1"]
+	_, tagged := c.tags["key1"]
+	c.mu.RUnlock()
+
+	if indexed || tagged {
+		t.Fatal("expected the expired key to be removed from the tag index")
+	}
+
+	c.Remove("key2")
+
+	c.mu.RLock()
+	n := len(c.tagged) + len(c.tags)
+	c.mu.RUnlock()
+
+	if n != 0 {
+		t.Fatalf("expected an empty tag index, but got %d entries", n)
+	}
+}
@@ -0,0 +1,163 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Tx gives direct access to the items of a cache while Do holds its write
+// lock. A Tx is only valid inside the function passed to Do.
+type Tx[K comparable, V any] struct {
+	c *Cache[K, V]
+}
+
+// Do runs f while holding the write lock, so every operation performed
+// through tx is atomic with respect to other cache operations. Calling
+// methods of the cache itself from inside f deadlocks, since they try to
+// acquire the same lock; use tx instead. On a closed cache f is not run.
+func (c *Cache[K, V]) Do(f func(tx *Tx[K, V])) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if blocked, _ := c.writeErr(); blocked {
+		return
+	}
+
+	f(&Tx[K, V]{c: c})
+}
+
+// Get retrieves the live value associated with key, deleting it if expired.
+func (tx *Tx[K, V]) Get(key K) (V, bool) {
+	return tx.c.lookup(key)
+}
+
+// Set inserts an item, replacing any existing one.
+func (tx *Tx[K, V]) Set(key K, data V, ttl time.Duration) {
+	tx.c.set(key, data, ttl)
+}
+
+// Remove deletes the item associated with key.
+func (tx *Tx[K, V]) Remove(key K) {
+	tx.c.delete(key)
+}
+
+// OpKind identifies the operation performed by an Op.
+type OpKind int
+
+const (
+	// OpSet stores the value like Set.
+	OpSet OpKind = iota
+
+	// OpRemove removes the key like Remove.
+	OpRemove
+
+	// OpReplace stores the value like Replace, failing unless the key holds
+	// a live item at that point of the batch.
+	OpReplace
+)
+
+// Op is a single operation of a batch passed to Apply or ApplyAll. It holds
+// plain data, so a batch can be logged or shipped to a replica.
+type Op[K comparable, V any] struct {
+	Kind  OpKind        `json:"kind"`
+	Key   K             `json:"key"`
+	Value V             `json:"value,omitempty"`
+	TTL   time.Duration `json:"ttl,omitempty"`
+}
+
+// Apply performs ops in order under a single write lock, so readers observe
+// either none or all of the changes. Failing operations are skipped and the
+// rest still apply; the returned error joins one error per failed
+// operation, identifying it by its index.
+func (c *Cache[K, V]) Apply(ops []Op[K, V]) error {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if blocked, err := c.writeErr(); blocked {
+		return err
+	}
+
+	var errs []error
+	for n, op := range ops {
+		if err := c.apply(op); err != nil {
+			errs = append(errs, fmt.Errorf("op %d: %w", n, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ApplyAll is like Apply, but applies ops only if every one of them would
+// succeed. Otherwise, it leaves the cache unchanged and reports the errors
+// of the failing operations. Capacity is not part of the check: with
+// OverflowReject, sets of new keys may still be dropped and reported.
+func (c *Cache[K, V]) ApplyAll(ops []Op[K, V]) error {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if blocked, err := c.writeErr(); blocked {
+		return err
+	}
+
+	// live tracks the keys written or removed by the preceding operations
+	// of the batch, so each operation is checked against the state it would
+	// actually find.
+	live := make(map[K]bool)
+
+	var errs []error
+	for n, op := range ops {
+		alive, seen := live[op.Key]
+		if !seen {
+			i, found := c.items[op.Key]
+			alive = found && (!c.expired(op.Key, i) || c.replaceExpired == ReplaceExpiredSet)
+		}
+
+		switch op.Kind {
+		case OpSet:
+			live[op.Key] = true
+		case OpRemove:
+			live[op.Key] = false
+		case OpReplace:
+			if !alive {
+				errs = append(errs, fmt.Errorf("op %d: item %v doesn't exist", n, op.Key))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("op %d: unknown operation kind %d", n, op.Kind))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	for n, op := range ops {
+		if err := c.apply(op); err != nil {
+			errs = append(errs, fmt.Errorf("op %d: %w", n, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// apply performs a single operation.
+func (c *Cache[K, V]) apply(op Op[K, V]) error {
+
+	switch op.Kind {
+	case OpSet:
+		if !c.set(op.Key, op.Value, op.TTL) {
+			return ErrFull
+		}
+	case OpRemove:
+		c.erase(op.Key)
+	case OpReplace:
+		return c.replace(op.Key, op.Value, op.TTL)
+	default:
+		return fmt.Errorf("unknown operation kind %d", op.Kind)
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCacheDo(t *testing.T) {
+
+	t.Parallel()
+
+	c := New[string, int](1 * time.Second)
+
+	c.Set("src", 10, 5*time.Second)
+	c.Set("moves", 0, 5*time.Second)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Do(func(tx *Tx[string, int]) {
+				moves, _ := tx.Get("moves")
+				tx.Set("moves", moves+1, 5*time.Second)
+
+				if value, found := tx.Get("src"); found {
+					tx.Remove("src")
+					tx.Set("dst", value, 5*time.Second)
+				}
+			})
+		}()
+	}
+	wg.Wait()
+
+	if moves, _ := c.Get("moves"); moves != 50 {
+		t.Fatalf("expected 50 moves, but got %d", moves)
+	}
+	if _, found := c.Get("src"); found {
+		t.Fatal("expected src to be moved")
+	}
+	if value, found := c.Get("dst"); !found || value != 10 {
+		t.Fatalf("expected dst to be 10, but got %v, found: %v", value, found)
+	}
+}
+
+func TestCacheApply(t *testing.T) {
+
+	t.Parallel()
+
+	c := New[string, int](1 * time.Second)
+
+	c.Set("key1", 10, 5*time.Second)
+
+	// should apply the valid operations and report the failing one.
+	err := c.Apply([]Op[string, int]{
+		{Kind: OpSet, Key: "key2", Value: 20, TTL: 5 * time.Second},
+		{Kind: OpReplace, Key: "missing", Value: 30, TTL: 5 * time.Second},
+		{Kind: OpRemove, Key: "key1"},
+		{Kind: OpReplace, Key: "key2", Value: 21, TTL: 5 * time.Second},
+	})
+	if err == nil || !strings.Contains(err.Error(), "op 1:") {
+		t.Fatalf("expected an error for op 1, but got %v", err)
+	}
+	if _, found := c.Get("key1"); found {
+		t.Fatal("expected key1 to be removed")
+	}
+	if value, found := c.Get("key2"); !found || value != 21 {
+		t.Fatalf("expected 21, but got %v, found: %v", value, found)
+	}
+}
+
+func TestCacheApplyAll(t *testing.T) {
+
+	t.Parallel()
+
+	c := New[string, int](1 * time.Second)
+
+	c.Set("key1", 10, 5*time.Second)
+
+	// should abort the whole batch, checking each op against the batch so far.
+	err := c.ApplyAll([]Op[string, int]{
+		{Kind: OpSet, Key: "key2", Value: 20, TTL: 5 * time.Second},
+		{Kind: OpRemove, Key: "key1"},
+		{Kind: OpReplace, Key: "key1", Value: 11, TTL: 5 * time.Second},
+	})
+	if err == nil || !strings.Contains(err.Error(), "op 2:") {
+		t.Fatalf("expected an error for op 2, but got %v", err)
+	}
+	if value, found := c.Get("key1"); !found || value != 10 {
+		t.Fatalf("expected key1 to be unchanged, but got %v, found: %v", value, found)
+	}
+	if _, found := c.Get("key2"); found {
+		t.Fatal("expected key2 not to be stored")
+	}
+
+	err = c.ApplyAll([]Op[string, int]{
+		{Kind: OpSet, Key: "key2", Value: 20, TTL: 5 * time.Second},
+		{Kind: OpReplace, Key: "key2", Value: 21, TTL: 5 * time.Second},
+		{Kind: OpRemove, Key: "key1"},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if value, found := c.Get("key2"); !found || value != 21 {
+		t.Fatalf("expected 21, but got %v, found: %v", value, found)
+	}
+}
@@ -1,18 +1,709 @@
 package cache
 
-import "time"
+import (
+	"fmt"
+	"math"
+	"time"
+	"unsafe"
+)
 
 func (i item[V]) isExpired() bool {
-	return time.Now().After(i.expiry)
+	deadline := i.deadline()
+	return !deadline.IsZero() && Now().After(deadline)
 }
 
-func (c *Cache[K, V]) set(key K, data V, ttl time.Duration) {
-	c.items[key] = item[V]{
-		value:  data,
-		expiry: time.Now().Add(ttl),
+// expired reports whether the item i stored under key has expired, either
+// by time or according to the function set with WithExpiryFunc.
+func (c *Cache[K, V]) expired(key K, i item[V]) bool {
+	return i.isExpired() || (c.expiryFunc != nil && c.expiryFunc(key, i.value, i.created))
+}
+
+// deadline returns the time the item expires, taking the idle timeout into
+// account. The zero time means the item never expires.
+func (i item[V]) deadline() time.Time {
+
+	if i.idle > 0 {
+		if idle := i.accessed.Add(i.idle); i.expiry.IsZero() || idle.Before(i.expiry) {
+			return idle
+		}
+	}
+
+	return i.expiry
+}
+
+// remaining returns the time left until the item expires, or math.MaxInt64
+// if it never expires.
+func (i item[V]) remaining(now time.Time) time.Duration {
+
+	deadline := i.deadline()
+	if deadline.IsZero() {
+		return math.MaxInt64
+	}
+
+	return deadline.Sub(now)
+}
+
+// set stores data under key with a fresh item expiring after ttl, reporting
+// whether it was stored, like store.
+func (c *Cache[K, V]) set(key K, data V, ttl time.Duration) bool {
+	now := Now()
+	return c.store(key, item[V]{
+		value:   data,
+		expiry:  c.expiryAfter(now, ttl),
+		ttl:     ttl,
+		created: now,
+	})
+}
+
+// expiryAfter returns the expiry of an item stored at now with the given
+// TTL, rounded up to the configured TTL granularity. In a cache created by
+// NewLRU, it returns the zero time, as items never expire.
+func (c *Cache[K, V]) expiryAfter(now time.Time, ttl time.Duration) time.Time {
+
+	if c.noExpiry {
+		return time.Time{}
+	}
+
+	expiry := now.Add(ttl)
+	if c.granularity <= 0 {
+		return expiry
+	}
+
+	if rounded := expiry.Truncate(c.granularity); rounded.Before(expiry) {
+		return rounded.Add(c.granularity)
+	}
+
+	return expiry
+}
+
+// store writes i under key, stamping it with the next version and keeping
+// the size accounting in sync. Storing a new key in a full cache evicts
+// items chosen by the eviction policy first, and the write is dropped if
+// none can be evicted and the overflow policy is OverflowReject, in which
+// case it reports false.
+func (c *Cache[K, V]) store(key K, i item[V]) bool {
+
+	c.counters.count(&c.counters.sets)
+
+	if old, found := c.items[key]; found {
+		c.bytes -= old.size
+	} else if !c.room(key) {
+		return false
+	}
+
+	if c.sizeOf != nil {
+		i.size = c.sizeOf(key, i.value)
+		c.bytes += i.size
+	}
+
+	c.version++
+	i.version = c.version
+
+	delete(c.tombstones, key)
+	c.items[key] = i
+	c.peak = max(c.peak, len(c.items))
+
+	if c.policy != nil {
+		if i.sticky {
+			c.policy.Remove(key)
+		} else {
+			c.policy.Touch(key, i.deadline())
+		}
+	}
+
+	c.emit(EventSet, key, i.value)
+	c.notifyEmptyState()
+
+	return true
+}
+
+// room makes room for storing key in a cache bounded by WithCapacity,
+// evicting items if needed. It reports false if the cache would exceed its
+// capacity and the overflow policy is OverflowReject.
+func (c *Cache[K, V]) room(key K) bool {
+
+	if _, found := c.items[key]; found || c.capacity <= 0 {
+		return true
+	}
+
+	return c.evict(c.capacity-1) || c.overflow != OverflowReject
+}
+
+// evict removes the items chosen by the eviction policy until at most n
+// remain, reporting whether it succeeded. Once the policy has no victim
+// left, sticky items are evicted too if the overflow policy is
+// OverflowEvictSticky.
+func (c *Cache[K, V]) evict(n int) bool {
+
+	// The item being stored replaces the evicted ones, so the cache isn't
+	// reported as empty in between.
+	c.evicting = true
+	defer func() { c.evicting = false }()
+
+	for len(c.items) > n {
+		victim, ok := c.policy.Victim()
+		if !ok && c.overflow == OverflowEvictSticky {
+			victim, ok = c.stickyVictim()
+		}
+		if !ok {
+			return false
+		}
+		c.spill(victim)
+		c.remove(victim, EventEvict)
+	}
+
+	return true
+}
+
+// stickyVictim returns the key of the sticky item expiring first, items that
+// never expire coming last.
+func (c *Cache[K, V]) stickyVictim() (K, bool) {
+
+	var victim K
+	var deadline time.Time
+	found := false
+
+	for key, i := range c.items {
+		if !i.sticky {
+			continue
+		}
+		if d := i.deadline(); !found || expiresBefore(d, deadline) {
+			victim, deadline, found = key, d, true
+		}
+	}
+
+	return victim, found
+}
+
+// get locks the cache and looks up key.
+func (c *Cache[K, V]) get(key K) (V, bool) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lookup(key)
+}
+
+// lookup returns the live value stored under key, deleting it if expired.
+func (c *Cache[K, V]) lookup(key K) (V, bool) {
+
+	c.counters.count(&c.counters.gets)
+
+	i, found := c.items[key]
+	if !found {
+		return i.value, false
 	}
+	if c.expired(key, i) {
+		// Expired items are kept for WithServeStaleOnError until a reload
+		// replaces them.
+		if !c.frozen && !c.keepsStale() {
+			c.expire(key)
+		}
+		return i.value, false
+	}
+
+	i.hits++
+	if i.idle > 0 && c.renew(&i) {
+		i.accessed = Now()
+	}
+	if c.hotWindow > 0 {
+		c.slide(&i)
+	}
+	c.items[key] = i
+
+	if c.policy != nil && !i.sticky {
+		c.policy.Touch(key, i.deadline())
+	}
+
+	return i.value, true
+}
+
+// slide counts a read of i in its current window and extends its expiry if
+// the item is hot, as configured with WithHotSliding.
+func (c *Cache[K, V]) slide(i *item[V]) {
+
+	now := Now()
+	if now.Sub(i.window) > c.hotWindow {
+		i.window, i.windowHits = now, 0
+	}
+
+	i.windowHits++
+	if i.windowHits >= c.hotMinHits && !c.frozen && c.renew(i) {
+		i.expiry = c.expiryAfter(now, i.ttl)
+	}
+}
+
+// renew counts an extension of the expiry of i by a read, reporting false
+// if i has used up the renewals allowed by WithMaxRenewals.
+func (c *Cache[K, V]) renew(i *item[V]) bool {
+
+	if c.maxRenewals > 0 && i.renewals >= c.maxRenewals {
+		return false
+	}
+
+	i.renewals++
+	return true
 }
 
 func (c *Cache[K, V]) delete(key K) {
+	c.counters.count(&c.counters.removes)
+	c.remove(key, EventRemove)
+}
+
+// erase deletes the item stored under key on behalf of the caller, leaving
+// a tombstone if tombstones are enabled.
+func (c *Cache[K, V]) erase(key K) {
+
+	if _, found := c.items[key]; found && c.tombstoneGrace > 0 {
+		c.bury(key, Now().Add(c.tombstoneGrace))
+	}
+
+	c.delete(key)
+}
+
+// replace stores data under key only if a live item is stored there, with
+// expired items handled according to the replace expired policy. The live
+// item's expiry is kept with WithReplacePreservesTTL.
+func (c *Cache[K, V]) replace(key K, data V, ttl time.Duration) error {
+
+	if i, found := c.items[key]; found {
+
+		if c.expired(key, i) {
+			switch c.replaceExpired {
+			case ReplaceExpiredSet:
+				c.set(key, data, ttl)
+				return nil
+			case ReplaceExpiredMissing:
+				return fmt.Errorf("item %v doesn't exist", key)
+			}
+
+			c.expire(key)
+			return fmt.Errorf("item %v is expired", key)
+		} else if c.replaceKeepsTTL {
+			// Only the expiry is kept: the item holds a new value.
+			i.value, i.created = data, Now()
+			c.store(key, i)
+			return nil
+		} else {
+			c.set(key, data, ttl)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("item %v doesn't exist", key)
+}
+
+// check runs the validator set with WithSetValidator, if any, on a value
+// about to be stored under key.
+func (c *Cache[K, V]) check(key K, data V) error {
+
+	if c.validate == nil {
+		return nil
+	}
+
+	return c.validate(key, data)
+}
+
+// expire deletes the item stored under key because it has expired.
+func (c *Cache[K, V]) expire(key K) {
+	c.remove(key, EventExpire)
+}
+
+// remove deletes the item stored under key and publishes an event of the
+// given type.
+func (c *Cache[K, V]) remove(key K, typ EventType) {
+
+	i, found := c.items[key]
+	if !found {
+		return
+	}
+
+	c.bytes -= i.size
 	delete(c.items, key)
+
+	if c.policy != nil {
+		c.policy.Remove(key)
+	}
+
+	if c.tags != nil {
+		c.untag(key)
+	}
+
+	c.emit(typ, key, i.value)
+	c.notifyEmptyState()
+
+	if c.dependents != nil {
+		c.unlink(key)
+
+		// Dependents are removed after key, so a dependency cycle ends
+		// when it reaches an item that is already gone.
+		children := c.dependents[key]
+		delete(c.dependents, key)
+
+		for child := range children {
+			c.remove(child, EventRemove)
+		}
+	}
+}
+
+// link records that key depends on each of parents.
+func (c *Cache[K, V]) link(key K, parents []K) {
+
+	if c.dependents == nil {
+		c.dependents = make(map[K]map[K]struct{})
+		c.dependencies = make(map[K][]K)
+	}
+
+	for _, parent := range parents {
+		if c.dependents[parent] == nil {
+			c.dependents[parent] = make(map[K]struct{})
+		}
+		c.dependents[parent][key] = struct{}{}
+	}
+
+	c.dependencies[key] = parents
+}
+
+// unlink forgets the dependencies of key.
+func (c *Cache[K, V]) unlink(key K) {
+
+	for _, parent := range c.dependencies[key] {
+		delete(c.dependents[parent], key)
+		if len(c.dependents[parent]) == 0 {
+			delete(c.dependents, parent)
+		}
+	}
+
+	delete(c.dependencies, key)
+}
+
+// tag records the tags of key, replacing any previous ones.
+func (c *Cache[K, V]) tag(key K, tags []string) {
+
+	if c.tags != nil {
+		c.untag(key)
+	}
+	if len(tags) == 0 {
+		return
+	}
+
+	if c.tags == nil {
+		c.tagged = make(map[string]map[K]struct{})
+		c.tags = make(map[K][]string)
+	}
+
+	for _, tag := range tags {
+		if c.tagged[tag] == nil {
+			c.tagged[tag] = make(map[K]struct{})
+		}
+		c.tagged[tag][key] = struct{}{}
+	}
+
+	c.tags[key] = tags
+}
+
+// untag forgets the tags of key.
+func (c *Cache[K, V]) untag(key K) {
+
+	for _, tag := range c.tags[key] {
+		delete(c.tagged[tag], key)
+		if len(c.tagged[tag]) == 0 {
+			delete(c.tagged, tag)
+		}
+	}
+
+	delete(c.tags, key)
+}
+
+// clear removes all items, or all but the sticky ones if keepSticky is set,
+// along with all tombstones and dependencies. Only the subscribers of the
+// removed keys receive EventRemove.
+func (c *Cache[K, V]) clear(keepSticky bool) {
+
+	for key, i := range c.items {
+		if keepSticky && i.sticky {
+			continue
+		}
+
+		c.bytes -= i.size
+		delete(c.items, key)
+
+		if c.policy != nil {
+			c.policy.Remove(key)
+		}
+		if c.tags != nil {
+			c.untag(key)
+		}
+
+		// Subscribers are told, so they don't keep showing a removed value,
+		// but the events channel isn't flooded with the whole cache.
+		if !c.closed {
+			for sub := range c.subscribers[key] {
+				sub.push(Event[K, V]{Type: EventRemove, Cache: c.name, Key: key, Value: i.value})
+			}
+		}
+	}
+
+	clear(c.tombstones)
+	clear(c.dependents)
+	clear(c.dependencies)
+
+	c.notifyEmptyState()
+}
+
+// notifyEmptyState invokes the empty state callback if the cache has become
+// empty or non-empty since it was last invoked.
+func (c *Cache[K, V]) notifyEmptyState() {
+
+	if c.onEmptyState == nil || c.evicting {
+		return
+	}
+
+	if populated := len(c.items) > 0; populated != c.populated {
+		c.populated = populated
+		c.onEmptyState(!populated)
+	}
+}
+
+// bury records a tombstone for key that lasts until expiry.
+func (c *Cache[K, V]) bury(key K, expiry time.Time) {
+
+	if c.tombstones == nil {
+		c.tombstones = make(map[K]time.Time)
+	}
+	c.tombstones[key] = expiry
+}
+
+// estimatedItemSize is the fallback per-item footprint used by MemoryUsage
+// when no size function is configured.
+func estimatedItemSize[K comparable, V any]() int64 {
+	var key K
+	return int64(unsafe.Sizeof(key) + unsafe.Sizeof(item[V]{}))
+}
+
+// cleanupLoop sweeps expired items every interval until the cache is closed.
+// With adaptive cleanup, the interval is recomputed after every pass. With a
+// cleanup schedule, the schedule is consulted at the start of every cycle and
+// the interval only applies to skipped cycles.
+func (c *Cache[K, V]) cleanupLoop(interval time.Duration) {
+
+	if c.adaptive {
+		interval = min(max(interval, c.minCleanup), c.maxCleanup)
+	}
+
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-timer.C:
+		}
+
+		if c.schedule != nil {
+			next := c.schedule(Now())
+			if next <= 0 {
+				timer.Reset(interval)
+				continue
+			}
+
+			c.sweep()
+			c.refreshAhead()
+			timer.Reset(next)
+			continue
+		}
+
+		report := c.sweep()
+		c.refreshAhead()
+
+		if c.adaptive {
+			interval = c.adaptInterval(interval, report)
+		}
+		timer.Reset(interval)
+	}
+}
+
+const (
+	// adaptiveSpeedUp is the expired fraction of a pass above which the
+	// adaptive cleanup interval is halved.
+	adaptiveSpeedUp = 0.25
+
+	// adaptiveSlowDown is the expired fraction of a pass below which the
+	// adaptive cleanup interval is doubled.
+	adaptiveSlowDown = 0.05
+
+	// minAdaptiveCleanup is the lower bound of the adaptive cleanup
+	// interval, whatever minimum is passed to WithAdaptiveCleanup.
+	minAdaptiveCleanup = time.Millisecond
+)
+
+// adaptInterval returns the interval to wait before the next cleanup pass:
+// shorter when the last pass found many expired items, longer when it found
+// few, always within the configured bounds.
+func (c *Cache[K, V]) adaptInterval(interval time.Duration, report CleanupReport) time.Duration {
+
+	var fraction float64
+	if report.Scanned > 0 {
+		fraction = float64(report.Expired) / float64(report.Scanned)
+	}
+
+	switch {
+	case fraction > adaptiveSpeedUp:
+		interval /= 2
+	case fraction < adaptiveSlowDown:
+		interval *= 2
+	}
+
+	return min(max(interval, c.minCleanup), c.maxCleanup)
+}
+
+// sweep removes all expired items, hands them to the sweep handler and
+// reports the pass to the cleanup observer, if any.
+func (c *Cache[K, V]) sweep() CleanupReport {
+
+	start := time.Now()
+
+	c.mu.Lock()
+
+	if c.frozen {
+		c.mu.Unlock()
+		return CleanupReport{Name: c.name}
+	}
+
+	type swept struct {
+		key   K
+		value V
+	}
+	var handled []swept
+	var batch []K
+
+	c.sweeping = c.batchExpiry && c.events != nil && !c.closed
+
+	// Expired items kept as a fallback for loader errors are left for a
+	// reload to replace.
+	keepStale := c.keepsStale()
+
+	scanned, expired := len(c.items), 0
+	for k, i := range c.items {
+		if !keepStale && c.expired(k, i) {
+			c.expire(k)
+			expired++
+
+			if c.sweepHandler != nil {
+				handled = append(handled, swept{k, i.value})
+			}
+			if c.sweeping {
+				batch = append(batch, k)
+			}
+		}
+	}
+
+	if c.sweeping && len(batch) > 0 {
+		c.publish(Event[K, V]{Type: EventBatchExpire, Cache: c.name, Keys: batch})
+	}
+	c.sweeping = false
+
+	now := Now()
+	for k, expiry := range c.tombstones {
+		if now.After(expiry) {
+			delete(c.tombstones, k)
+		}
+	}
+
+	compacted := c.autoCompact > 0 && float64(len(c.items)) < c.autoCompact*float64(c.peak)
+	if compacted {
+		c.compact()
+	}
+
+	c.mu.Unlock()
+
+	for _, e := range handled {
+		c.sweepHandler(e.key, e.value)
+	}
+
+	report := CleanupReport{
+		Name:      c.name,
+		Scanned:   scanned,
+		Expired:   expired,
+		Compacted: compacted,
+		Took:      time.Since(start),
+	}
+
+	if c.cleanupObserver != nil {
+		c.cleanupObserver(report)
+	}
+
+	return report
+}
+
+// compact rebuilds the item map, releasing the storage left over by deleted
+// items.
+func (c *Cache[K, V]) compact() {
+
+	items := make(map[K]item[V], len(c.items))
+	for key, i := range c.items {
+		items[key] = i
+	}
+
+	c.items = items
+	c.peak = len(items)
+}
+
+// equal compares two values with the configured equality function, falling
+// back to ==.
+func (c *Cache[K, V]) equal(a, b V) bool {
+
+	if c.equals != nil {
+		return c.equals(a, b)
+	}
+
+	return any(a) == any(b)
+}
+
+// clone copies a value about to be returned to the caller with the
+// configured cloner, if any.
+func (c *Cache[K, V]) clone(value V) V {
+
+	if c.cloner != nil {
+		return c.cloner(value)
+	}
+
+	return value
+}
+
+// writeErr reports whether a mutating method must be skipped because the
+// cache is closed or frozen, along with the error it should return.
+func (c *Cache[K, V]) writeErr() (bool, error) {
+
+	if closed, err := c.closedErr(); closed {
+		return true, err
+	}
+
+	if c.frozen {
+		if c.frozenPolicy == FrozenIgnore {
+			return true, nil
+		}
+		return true, ErrFrozen
+	}
+
+	return false, nil
+}
+
+// closedErr reports whether the cache is closed, along with the error a
+// mutating method should return according to the closed policy.
+func (c *Cache[K, V]) closedErr() (bool, error) {
+
+	if !c.closed {
+		return false, nil
+	}
+
+	switch c.closedPolicy {
+	case ClosedIgnore:
+		return true, nil
+	case ClosedPanic:
+		panic(ErrClosed)
+	}
+
+	return true, ErrClosed
 }
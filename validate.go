@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Validate checks the internal bookkeeping of the cache for consistency and
+// returns an error describing every problem found, or nil. It walks all the
+// internal structures under the read lock, so it is meant for tests and
+// debugging rather than production paths.
+func (c *Cache[K, V]) Validate() error {
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var errs []error
+
+	var bytes int64
+	var sticky int
+	for key, i := range c.items {
+		if i.sticky {
+			sticky++
+		}
+		if i.size < 0 {
+			errs = append(errs, fmt.Errorf("item %v has negative size %d", key, i.size))
+		}
+		if i.version > c.version {
+			errs = append(errs, fmt.Errorf("item %v has version %d beyond the cache version %d", key, i.version, c.version))
+		}
+		if _, buried := c.tombstones[key]; buried {
+			errs = append(errs, fmt.Errorf("item %v is both stored and tombstoned", key))
+		}
+		bytes += i.size
+	}
+
+	if bytes != c.bytes {
+		errs = append(errs, fmt.Errorf("size accounting is %d bytes, but items add up to %d", c.bytes, bytes))
+	}
+
+	// Sticky items can't be evicted, so once they fill the capacity, a
+	// single regular item at a time is stored alongside them.
+	if c.capacity > 0 && len(c.items) > max(c.capacity, sticky+1) {
+		errs = append(errs, fmt.Errorf("cache holds %d items, beyond its capacity of %d", len(c.items), c.capacity))
+	}
+
+	if p, ok := c.policy.(interface{ Len() int }); ok && p.Len() != len(c.items)-sticky {
+		errs = append(errs, fmt.Errorf("eviction policy tracks %d keys, but the cache holds %d items", p.Len(), len(c.items)-sticky))
+	}
+
+	for key, parents := range c.dependencies {
+		if _, found := c.items[key]; !found {
+			errs = append(errs, fmt.Errorf("dependencies recorded for missing item %v", key))
+		}
+		for _, parent := range parents {
+			if _, linked := c.dependents[parent][key]; !linked {
+				errs = append(errs, fmt.Errorf("item %v depends on %v, which doesn't list it as a dependent", key, parent))
+			}
+		}
+	}
+
+	for parent, children := range c.dependents {
+		for child := range children {
+			if !slices.Contains(c.dependencies[child], parent) {
+				errs = append(errs, fmt.Errorf("item %v lists %v as a dependent, which doesn't depend on it", parent, child))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"testing"
+	"time"
+)
+
+func TestCacheValidate(t *testing.T) {
+
+	t.Parallel()
+
+	c := New(1*time.Second,
+		WithManualCleanup[string, string](),
+		WithCapacity[string, string](20),
+		WithTombstones[string, string](5*time.Second),
+		WithSizeFunc(func(key string, value string) int64 {
+			return int64(len(value))
+		}),
+	)
+
+	// A random mix of operations must leave the bookkeeping consistent.
+	for n := 0; n < 2000; n++ {
+		key := fmt.Sprintf("key%d", rand.IntN(40))
+		value := fmt.Sprint(n)
+		ttl := time.Duration(rand.IntN(3)) * time.Second
+
+		switch rand.IntN(6) {
+		case 0:
+			c.Set(key, value, ttl)
+		case 1:
+			c.SetWithDeps(key, value, ttl, fmt.Sprintf("key%d", rand.IntN(40)))
+		case 2:
+			c.Remove(key)
+		case 3:
+			c.Pop(key)
+		case 4:
+			c.Get(key)
+		case 5:
+			c.RunCleanup()
+		}
+
+		if err := c.Validate(); err != nil {
+			t.Fatalf("after operation %d: %v", n, err)
+		}
+	}
+
+	// should detect drifting size accounting.
+	c.mu.Lock()
+	c.bytes++
+	c.mu.Unlock()
+
+	if err := c.Validate(); err == nil {
+		t.Fatal("expected an inconsistency to be reported, but got none")
+	}
+}